@@ -0,0 +1,34 @@
+// Benchmark for verifying a list of structs as received from the server, with
+// the client module given as argument. See run.sh.
+
+const api = await import(process.argv[2])
+
+const items: any[] = []
+for (let i = 0; i < 10000; i++) {
+	items.push({
+		ID: i,
+		Name: 'item ' + i,
+		Active: i % 2 === 0,
+		Count: i % 1000,
+		Score: i / 3,
+		Created: '2024-01-02T03:04:05.123Z',
+		Kind: i % 2 === 0 ? 'a' : 'b',
+		Tags: ['x', 'y'],
+		Parent: i > 0 ? i - 1 : null,
+	})
+}
+
+const verify = () => api.verifyArg('result', items, ['[]', 'Item'], true, true, api.types, {})
+
+// Warm up, letting the JIT compile the verification code.
+for (let i = 0; i < 20; i++) {
+	verify()
+}
+
+const rounds = 100
+const start = performance.now()
+for (let i = 0; i < rounds; i++) {
+	verify()
+}
+const msec = (performance.now() - start) / rounds
+console.log(process.argv[2] + ': ' + msec.toFixed(1) + 'ms per verification of ' + items.length + ' structs')
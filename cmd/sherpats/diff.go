@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mjl-/sherpats"
+)
+
+// diffCmd compares two sherpadoc files, prints the changes and exits with status
+// 1 if any change is breaking. Invalid usage and errors reading the sherpadoc
+// files exit with status 2, like diff(1).
+func diffCmd(args []string) {
+	var opts sherpats.Options
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.BoolVar(&opts.BytesToString, "bytes-to-string", false, "turn []uint8 into string before comparing, as for generating")
+	fs.BoolVar(&opts.NamedParams, "named-params", false, "report renamed parameters as breaking, for clients generated with -named-params")
+	fs.StringVar(&opts.Results, "results", "tuple", "report renamed return values as breaking with object, for clients generated with -results object")
+	fs.Usage = func() {
+		log.Println("usage: sherpats diff [flags] old.json new.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	oldf, err := os.Open(args[0])
+	diffCheck(err, "open old sherpadoc")
+	defer oldf.Close()
+	newf, err := os.Open(args[1])
+	diffCheck(err, "open new sherpadoc")
+	defer newf.Close()
+
+	changes, err := sherpats.Diff(oldf, newf, opts)
+	diffCheck(err, "comparing sherpadoc")
+	breaking := 0
+	for _, c := range changes {
+		fmt.Println(c)
+		if c.Breaking {
+			breaking++
+		}
+	}
+	if breaking > 0 {
+		log.Printf("%d of %d changes are breaking", breaking, len(changes))
+		os.Exit(1)
+	}
+}
+
+// diffCheck is like check, but exits with status 2, so errors can be told apart
+// from breaking changes.
+func diffCheck(err error, action string) {
+	if err != nil {
+		log.Printf("%s: %s\n", action, err)
+		os.Exit(2)
+	}
+}
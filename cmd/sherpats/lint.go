@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mjl-/sherpats"
+)
+
+// lintCmd prints findings about a sherpadoc file, or stdin, and exits with
+// status 1 if there are errors, or with -strict, any findings.
+func lintCmd(args []string) {
+	var opts sherpats.Options
+	var strict bool
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.BoolVar(&opts.BytesToString, "bytes-to-string", false, "don't warn about []uint8, it is turned into string when generating")
+	fs.BoolVar(&strict, "strict", false, "exit with status 1 for warnings too")
+	fs.Usage = func() {
+		log.Println("usage: sherpats lint [flags] [sherpadoc.json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		check(err, "open sherpadoc")
+		defer f.Close()
+		in = f
+	}
+
+	findings, err := sherpats.Lint(in, opts)
+	check(err, "linting sherpadoc")
+	fail := false
+	for _, f := range findings {
+		fmt.Println(f)
+		fail = fail || strict || f.Severity == "error"
+	}
+	if fail {
+		os.Exit(1)
+	}
+}
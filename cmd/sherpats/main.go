@@ -7,10 +7,30 @@
 //
 //	sherpadoc MyAPI >myapi.json
 //	sherpats -bytes-to-string -slices-nullable -nullable-optional -namespace myapi myapi < myapi.json > myapi.ts
+//
+// With -explorer, an HTML page is written as well, with a form for calling each
+// function through the generated client. The page loads the client, compiled to
+// JavaScript, from the URL set with -explorer-client:
+//
+//	sherpats -explorer myapi.html -explorer-client ./myapi.js myapi < myapi.json > myapi.ts
+//
+// Named types can be mapped to other TypeScript types, e.g. a decimal class, with
+// functions converting values from and to JSON, see -type-map.
+//
+// To compare two versions of an API, printing changes and exiting with status 1
+// if changes can break existing TypeScript clients, or with status 2 on errors:
+//
+//	sherpats diff old.json new.json
+//
+// To check a sherpadoc for missing documentation and other problems:
+//
+//	sherpats lint myapi.json
 package main
 
 import (
+	"bytes"
 	"flag"
+	"io/ioutil"
 	"log"
 	"os"
 
@@ -26,14 +46,53 @@ func check(err error, action string) {
 func main() {
 	log.SetFlags(0)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			diffCmd(os.Args[2:])
+			return
+		case "lint":
+			lintCmd(os.Args[2:])
+			return
+		}
+	}
+
 	var opts sherpats.Options
+	var explorer, explorerClient string
+	var reportRenames bool
+	var typeMap string
 	flag.StringVar(&opts.Namespace, "namespace", "", "namespace to enclose generated typescript in")
 	flag.BoolVar(&opts.SlicesNullable, "slices-nullable", false, "generate nullable types in TypeScript for Go slices, to require TypeScript checks for null for slices")
 	flag.BoolVar(&opts.MapsNullable, "maps-nullable", false, "generate nullable types in TypeScript for Go maps, to require TypeScript checks for null for maps")
 	flag.BoolVar(&opts.NullableOptional, "nullable-optional", false, "for nullable types (include slices with -slices-nullable=true), generate optional fields in TypeScript and allow undefined as value")
 	flag.BoolVar(&opts.BytesToString, "bytes-to-string", false, "turn []uint8, also known as []byte, into string before generating the api, matching Go's JSON package that marshals []byte as base64-encoded string")
+	flag.BoolVar(&opts.BigInt, "bigint", false, "generate bigint instead of number for int64/uint64 and instead of string for int64s/uint64s, parsing responses without precision loss and rejecting out of range values; requires ES2020")
+	flag.StringVar(&opts.Timestamp, "timestamp", "date", "typescript representation for timestamps: date for Date (millisecond precision), string for RFC3339 string as sent by the server, temporal for Temporal.Instant (nanosecond precision)")
+	flag.StringVar(&opts.Enums, "enums", "enum", "typescript representation for ints and strings types with values: enum for a typescript enum, union for a union of literal values with a frozen array of values, const-object for an object \"as const\" with a derived type")
+	flag.BoolVar(&opts.EnumHelpers, "enum-helpers", false, "generate helpers for ints and strings types with values: <Type>Values, <Type>Names, <Type>Labels (from documentation) and <type>FromString")
+	flag.BoolVar(&opts.Guards, "guards", false, "generate a type guard isFoo for each named type Foo, returning whether an in-memory value (e.g. with Date for timestamps) is valid for the type, e.g. for data from other sources than the API; use parser or safeParser for JSON values")
+	flag.BoolVar(&opts.SafeParser, "safe-parser", false, "generate safeParser, like parser, but returning all issues with a value instead of throwing an exception for the first")
+	flag.BoolVar(&opts.Constructors, "constructors", false, "generate a function newFoo for each struct Foo, returning a Foo with Go zero values for fields, and fields from an optional partial Foo")
+	flag.BoolVar(&opts.CloneEqual, "clone-equal", false, "generate functions clone and equal for deep copies and structural comparison of values of named types, e.g. for checking if a form has changes; requires -runtime-types full")
+	flag.BoolVar(&opts.Readonly, "readonly", false, "generate readonly types: readonly struct fields, ReadonlyArray for slices, Readonly<Record> for maps and readonly tuples for multiple return values; mutable values can still be passed as parameters")
+	flag.BoolVar(&opts.Unknown, "unknown", false, "generate unknown instead of any for values of sherpadoc type any, for values passed to parser and safeParser, and in generated functions; the included runtime library still uses any")
+	flag.BoolVar(&opts.SplitTypes, "split-types", false, "also generate types FooIn for parameters and FooOut for results for struct Foo, if they differ, e.g. in timestamps that can be passed as string, optional fields with -nullable-optional, or readonly results")
+	flag.BoolVar(&opts.JSDoc, "jsdoc", false, "generate documentation as JSDoc comments, shown by editors on hover, with links to types and tags like @deprecated")
+	flag.BoolVar(&opts.WarnDeprecated, "warn-deprecated", false, "log a warning with stack trace to the console the first time a deprecated function is called, i.e. with a paragraph starting with \"Deprecated:\" in its documentation")
+	flag.IntVar(&opts.DocWidth, "doc-width", 0, "if > 0, wrap lines of documentation longer than this width at spaces")
+	flag.StringVar(&opts.RuntimeTypes, "runtime-types", "full", "runtime type information for verifying values: full for a table with all types, lazy for tables with only the types needed per function so bundlers can leave out unused types, none for no runtime verification and smaller code (requires -timestamp string)")
+	flag.BoolVar(&opts.NamedParams, "named-params", false, "generate functions taking a single object with the parameters as properties, instead of positional parameters")
+	flag.StringVar(&opts.Results, "results", "tuple", "typescript representation for multiple return values of a function: tuple, labeled-tuple for a tuple with names of the return values as labels, object for an object with the names as properties")
+	flag.BoolVar(&opts.StandaloneFunctions, "standalone-functions", false, "also generate an exported function for each api function, with a ClientConfig (e.g. a Client) as first parameter, so bundlers can leave out unused functions")
+	flag.BoolVar(&opts.CompiledChecks, "compiled-checks", false, "generate checks for fields of structs and parameters and results of functions, for faster verification of values, instead of interpreting typewords at runtime")
+	flag.StringVar(&typeMap, "type-map", "", "json file with mappings of named types to other typescript types, an array of objects with fields Name, and optional Import, Type, Parse and Serialize")
+	flag.BoolVar(&reportRenames, "report-renames", false, "print identifiers from the sherpadoc that are renamed in the generated code, e.g. because they are typescript keywords, and renamed names generated for types, to stderr")
+	flag.StringVar(&explorer, "explorer", "", "if set, also write an HTML page for interactively calling the API functions to this file")
+	flag.StringVar(&explorerClient, "explorer-client", "./api.js", "URL of the generated client compiled to JavaScript, loaded by the explorer page")
 	flag.Usage = func() {
 		log.Println("usage: sherpats [flags] { api-path-elem | baseURL }")
+		log.Println("       sherpats diff [flags] old.json new.json")
+		log.Println("       sherpats lint [flags] [sherpadoc.json]")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -44,7 +103,29 @@ func main() {
 		os.Exit(2)
 	}
 	apiName := args[0]
+	if reportRenames {
+		opts.RenameReport = os.Stderr
+	}
+	if typeMap != "" {
+		f, err := os.Open(typeMap)
+		check(err, "opening type map")
+		opts.TypeMappings, err = sherpats.ParseTypeMappings(f)
+		check(err, "parsing type map")
+		f.Close()
+	}
+
+	buf, err := ioutil.ReadAll(os.Stdin)
+	check(err, "reading sherpadoc")
+
+	if explorer != "" {
+		f, err := os.Create(explorer)
+		check(err, "creating explorer file")
+		err = sherpats.GenerateExplorer(bytes.NewReader(buf), f, explorerClient, opts)
+		check(err, "generating explorer")
+		err = f.Close()
+		check(err, "closing explorer file")
+	}
 
-	err := sherpats.Generate(os.Stdin, os.Stdout, apiName, opts)
+	err = sherpats.Generate(bytes.NewReader(buf), os.Stdout, apiName, opts)
 	check(err, "generating typescript client")
 }
@@ -0,0 +1,433 @@
+package sherpats
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/mjl-/sherpadoc"
+)
+
+// Change is a difference between two versions of an API, as returned by Diff.
+type Change struct {
+	// Whether TypeScript clients generated for the old API can break when talking to
+	// the new API, either at compile time or at runtime.
+	Breaking bool
+
+	// Location of the change, e.g. "function Login, param name".
+	Path string
+
+	Message string
+}
+
+func (c Change) String() string {
+	kind := "compatible"
+	if c.Breaking {
+		kind = "breaking"
+	}
+	return fmt.Sprintf("%s: %s: %s", kind, c.Path, c.Message)
+}
+
+// Diff reads an old and a new sherpadoc and returns the changes between them,
+// first for functions, then for named types. Changes are classified as
+// breaking or compatible from the perspective of a TypeScript client generated
+// from the old sherpadoc. Whether a change in a named type is breaking can depend
+// on whether the type is used in parameters, return values or both. For example,
+// a new enum value is fine in parameters, but a generated client rejects the
+// unknown value in return values.
+//
+// Renamed types are detected by comparing the definitions of removed and added
+// types, and are reported as breaking. With opts.NamedParams, renamed parameters
+// are breaking too, as are renamed return values with opts.Results "object".
+func Diff(oldIn, newIn io.Reader, opts Options) (changes []Change, retErr error) {
+	defer recoverGenError(&retErr)
+
+	oldDoc := parseSherpadoc(oldIn, opts)
+	newDoc := parseSherpadoc(newIn, opts)
+
+	d := &differ{
+		opts:      opts,
+		oldTypes:  namedTypes(&oldDoc),
+		newTypes:  namedTypes(&newDoc),
+		renamed:   map[string]string{},
+		paramUse:  map[string]bool{},
+		resultUse: map[string]bool{},
+	}
+	d.findRenames()
+	d.markUse(&oldDoc)
+
+	oldFns := functions(&oldDoc)
+	newFns := map[string]*sherpadoc.Function{}
+	for _, fn := range functions(&newDoc) {
+		newFns[fn.Name] = fn
+	}
+	for _, ofn := range oldFns {
+		nfn, ok := newFns[ofn.Name]
+		if !ok {
+			d.add(true, "function "+ofn.Name, "removed")
+			continue
+		}
+		d.diffFunction(ofn, nfn)
+	}
+	oldFnNames := map[string]bool{}
+	for _, fn := range oldFns {
+		oldFnNames[fn.Name] = true
+	}
+	for _, fn := range functions(&newDoc) {
+		if !oldFnNames[fn.Name] {
+			d.add(false, "function "+fn.Name, "added")
+		}
+	}
+
+	for _, ot := range d.oldTypes {
+		name := ot.name
+		if nname, ok := d.renamed[name]; ok {
+			d.add(true, ot.kind+" "+name, "renamed to %s", nname)
+			name = nname
+		}
+		nt := d.newTypes.find(name)
+		if nt == nil {
+			d.add(true, ot.kind+" "+ot.name, "removed")
+			continue
+		}
+		d.diffType(ot, nt)
+	}
+	renamedTo := map[string]bool{}
+	for _, nname := range d.renamed {
+		renamedTo[nname] = true
+	}
+	for _, nt := range d.newTypes {
+		if d.oldTypes.find(nt.name) == nil && !renamedTo[nt.name] {
+			d.add(false, nt.kind+" "+nt.name, "added")
+		}
+	}
+	return d.changes, nil
+}
+
+// namedType is a struct, ints or strings type from a sherpadoc.
+type namedType struct {
+	kind   string // "struct", "ints" or "strings"
+	name   string
+	fields []sherpadoc.Field
+	values []namedValue
+}
+
+type namedValue struct {
+	Name  string
+	Value interface{}
+}
+
+type namedTypeList []*namedType
+
+func (l namedTypeList) find(name string) *namedType {
+	for _, t := range l {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func namedTypes(sec *sherpadoc.Section) namedTypeList {
+	var l namedTypeList
+	for _, t := range sec.Structs {
+		l = append(l, &namedType{kind: "struct", name: t.Name, fields: t.Fields})
+	}
+	for _, t := range sec.Ints {
+		nt := &namedType{kind: "ints", name: t.Name}
+		for _, v := range t.Values {
+			nt.values = append(nt.values, namedValue{v.Name, v.Value})
+		}
+		l = append(l, nt)
+	}
+	for _, t := range sec.Strings {
+		nt := &namedType{kind: "strings", name: t.Name}
+		for _, v := range t.Values {
+			nt.values = append(nt.values, namedValue{v.Name, v.Value})
+		}
+		l = append(l, nt)
+	}
+	for _, subsec := range sec.Sections {
+		l = append(l, namedTypes(subsec)...)
+	}
+	return l
+}
+
+func functions(sec *sherpadoc.Section) []*sherpadoc.Function {
+	l := append([]*sherpadoc.Function{}, sec.Functions...)
+	for _, subsec := range sec.Sections {
+		l = append(l, functions(subsec)...)
+	}
+	return l
+}
+
+type differ struct {
+	opts               Options
+	oldTypes, newTypes namedTypeList
+	renamed            map[string]string // Old to new type name.
+
+	// Named types of the old API used in parameters and return values, including
+	// through other named types.
+	paramUse, resultUse map[string]bool
+
+	changes []Change
+}
+
+func (d *differ) add(breaking bool, path, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{breaking, path, fmt.Sprintf(format, args...)})
+}
+
+// findRenames matches types that only exist in the old API to types with an
+// identical definition that only exist in the new API. References to other
+// types that exist in only one of the APIs are treated as equal, so types that
+// reference each other, or themselves, can be matched. Enums without values and
+// structs without fields have nothing to compare, and are never matched.
+func (d *differ) findRenames() {
+	oldOnly := map[string]bool{}
+	for _, ot := range d.oldTypes {
+		if d.newTypes.find(ot.name) == nil {
+			oldOnly[ot.name] = true
+		}
+	}
+	newOnly := map[string]bool{}
+	for _, nt := range d.newTypes {
+		if d.oldTypes.find(nt.name) == nil {
+			newOnly[nt.name] = true
+		}
+	}
+
+	taken := map[string]bool{}
+	for _, ot := range d.oldTypes {
+		if !oldOnly[ot.name] || len(ot.fields) == 0 && len(ot.values) == 0 {
+			continue
+		}
+		for _, nt := range d.newTypes {
+			if !newOnly[nt.name] || taken[nt.name] {
+				continue
+			}
+			if ot.kind == nt.kind && reflect.DeepEqual(typeSignature(ot, oldOnly), typeSignature(nt, newOnly)) {
+				d.renamed[ot.name] = nt.name
+				taken[nt.name] = true
+				break
+			}
+		}
+	}
+}
+
+// typeSignature returns the definition of a type without names and
+// documentation, and with the names of types in unmatched replaced by "*".
+func typeSignature(t *namedType, unmatched map[string]bool) interface{} {
+	if t.kind != "struct" {
+		return t.values
+	}
+	var l [][]string
+	for _, f := range t.fields {
+		sig := []string{f.Name}
+		for _, w := range f.Typewords {
+			if unmatched[w] {
+				w = "*"
+			}
+			sig = append(sig, w)
+		}
+		l = append(l, sig)
+	}
+	return l
+}
+
+func (d *differ) markUse(sec *sherpadoc.Section) {
+	var mark func(use map[string]bool, tw []string)
+	mark = func(use map[string]bool, tw []string) {
+		for _, w := range tw {
+			t := d.oldTypes.find(w)
+			if t == nil || use[w] {
+				continue
+			}
+			use[w] = true
+			for _, f := range t.fields {
+				mark(use, f.Typewords)
+			}
+		}
+	}
+	for _, fn := range functions(sec) {
+		for _, p := range fn.Params {
+			mark(d.paramUse, p.Typewords)
+		}
+		for _, r := range fn.Returns {
+			mark(d.resultUse, r.Typewords)
+		}
+	}
+}
+
+func (d *differ) diffFunction(ofn, nfn *sherpadoc.Function) {
+	path := "function " + ofn.Name
+	if len(ofn.Params) != len(nfn.Params) {
+		d.add(true, path, "number of parameters changed from %d to %d", len(ofn.Params), len(nfn.Params))
+	} else {
+		for i, op := range ofn.Params {
+			otw, ntw := d.rename(op.Typewords), nfn.Params[i].Typewords
+			if d.opts.NamedParams && op.Name != nfn.Params[i].Name {
+				d.add(true, path+", param "+op.Name, "renamed to %s", nfn.Params[i].Name)
+			}
+			// Values sent by the client must be accepted by the new API.
+			d.diffTypewords(path+", param "+op.Name, otw, ntw, accepts(ntw, otw))
+		}
+	}
+	if len(ofn.Returns) != len(nfn.Returns) {
+		d.add(true, path, "number of return values changed from %d to %d", len(ofn.Returns), len(nfn.Returns))
+	} else {
+		for i, or := range ofn.Returns {
+			otw, ntw := d.rename(or.Typewords), nfn.Returns[i].Typewords
+			if resultsMode(d.opts) == "object" && len(ofn.Returns) > 1 && or.Name != nfn.Returns[i].Name {
+				d.add(true, fmt.Sprintf("%s, return value %d", path, i), "renamed from %s to %s", or.Name, nfn.Returns[i].Name)
+			}
+			// Values returned by the new API must be accepted by the client.
+			d.diffTypewords(fmt.Sprintf("%s, return value %d", path, i), otw, ntw, accepts(otw, ntw))
+		}
+	}
+}
+
+// diffTypewords adds a change if the old typewords, with renames already
+// applied, differ from the new typewords.
+func (d *differ) diffTypewords(path string, otw, ntw []string, compatible bool) {
+	if sameTypewords(otw, ntw) {
+		return
+	}
+	msg := fmt.Sprintf("type changed from %s to %s", strings.Join(otw, " "), strings.Join(ntw, " "))
+	if otw[0] != "nullable" && ntw[0] == "nullable" && sameTypewords(otw, ntw[1:]) {
+		msg = "made nullable"
+	} else if otw[0] == "nullable" && ntw[0] != "nullable" && sameTypewords(otw[1:], ntw) {
+		msg = "made non-nullable"
+	}
+	d.add(!compatible, path, "%s", msg)
+}
+
+// rename returns typewords of the old API with renames of named types applied.
+func (d *differ) rename(tw []string) []string {
+	r := make([]string, len(tw))
+	for i, w := range tw {
+		if nw, ok := d.renamed[w]; ok {
+			w = nw
+		}
+		r[i] = w
+	}
+	return r
+}
+
+func sameTypewords(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Ranges of the integer types, for widening.
+var intRanges = map[string][2]float64{
+	"int8":   {-1 << 7, 1<<7 - 1},
+	"uint8":  {0, 1<<8 - 1},
+	"int16":  {-1 << 15, 1<<15 - 1},
+	"uint16": {0, 1<<16 - 1},
+	"int32":  {-1 << 31, 1<<31 - 1},
+	"uint32": {0, 1<<32 - 1},
+	"int64":  {-1 << 63, 1<<63 - 1},
+	"uint64": {0, 1<<64 - 1},
+}
+
+// accepts returns whether all values of type src are valid values for type dst.
+// Named types are compared by name only, their definitions are compared
+// separately.
+func accepts(dst, src []string) bool {
+	if sameTypewords(src, dst) {
+		return true
+	}
+	switch {
+	case dst[0] == "nullable" && src[0] == "nullable":
+		return accepts(dst[1:], src[1:])
+	case dst[0] == "nullable":
+		return accepts(dst[1:], src)
+	case src[0] == "nullable":
+		return false
+	case dst[0] == "[]" && src[0] == "[]", dst[0] == "{}" && src[0] == "{}":
+		return accepts(dst[1:], src[1:])
+	case len(dst) != 1 || len(src) != 1:
+		return false
+	case dst[0] == "any":
+		return true
+	case dst[0] == "float64" && (src[0] == "float32" || src[0] == "int8" || src[0] == "uint8" || src[0] == "int16" || src[0] == "uint16" || src[0] == "int32" || src[0] == "uint32"):
+		return true
+	}
+	dr, dok := intRanges[dst[0]]
+	sr, sok := intRanges[src[0]]
+	return dok && sok && dr[0] <= sr[0] && dr[1] >= sr[1]
+}
+
+func (d *differ) diffType(ot, nt *namedType) {
+	path := ot.kind + " " + ot.name
+	if ot.kind != nt.kind {
+		d.add(true, path, "changed from %s to %s", ot.kind, nt.kind)
+		return
+	}
+	inParams := d.paramUse[ot.name]
+	inResults := d.resultUse[ot.name]
+
+	if ot.kind == "struct" {
+		newFields := map[string]sherpadoc.Field{}
+		for _, f := range nt.fields {
+			newFields[f.Name] = f
+		}
+		oldFields := map[string]bool{}
+		for _, of := range ot.fields {
+			oldFields[of.Name] = true
+			fpath := path + ", field " + of.Name
+			nf, ok := newFields[of.Name]
+			if !ok {
+				d.add(true, fpath, "removed")
+				continue
+			}
+			otw, ntw := d.rename(of.Typewords), nf.Typewords
+			compatible := (!inParams || accepts(ntw, otw)) && (!inResults || accepts(otw, ntw))
+			d.diffTypewords(fpath, otw, ntw, compatible)
+		}
+		for _, nf := range nt.fields {
+			if !oldFields[nf.Name] {
+				// Clients don't send the field, the server gets a zero value. Clients ignore unknown fields in results.
+				d.add(false, path+", field "+nf.Name, "added")
+			}
+		}
+		return
+	}
+
+	if len(ot.values) > 0 && len(nt.values) == 0 {
+		d.add(false, path, "values removed, any value allowed")
+		return
+	} else if len(ot.values) == 0 && len(nt.values) > 0 {
+		d.add(inParams, path, "values added, previously any value allowed")
+		return
+	}
+	newValues := map[string]namedValue{}
+	for _, v := range nt.values {
+		newValues[v.Name] = v
+	}
+	oldValues := map[string]bool{}
+	for _, ov := range ot.values {
+		oldValues[ov.Name] = true
+		vpath := path + ", value " + ov.Name
+		nv, ok := newValues[ov.Name]
+		if !ok {
+			d.add(true, vpath, "removed")
+		} else if nv.Value != ov.Value {
+			d.add(true, vpath, "changed from %v to %v", mustMarshalJSON(ov.Value), mustMarshalJSON(nv.Value))
+		}
+	}
+	for _, nv := range nt.values {
+		if !oldValues[nv.Name] {
+			// Generated clients verify that values in results are known.
+			d.add(inResults, path+", value "+nv.Name, "added")
+		}
+	}
+}
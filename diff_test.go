@@ -0,0 +1,187 @@
+package sherpats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDoc returns a sherpadoc in JSON with the functions, structs and strings
+// types, each a comma-separated list of JSON objects.
+func testDoc(functions, structs, strs string) string {
+	return `{"Name": "Test", "Docs": "", "SherpaVersion": 0, "SherpadocVersion": 1, "Functions": [` + functions + `], "Sections": [], "Structs": [` + structs + `], "Ints": [], "Strings": [` + strs + `]}`
+}
+
+const (
+	testFnGet = `{"Name": "Get", "Docs": "", "Params": [{"Name": "id", "Typewords": ["int32"]}], "Returns": [{"Name": "r0", "Typewords": ["User"]}]}`
+	testFnPut = `{"Name": "Put", "Docs": "", "Params": [{"Name": "u", "Typewords": ["User"]}], "Returns": []}`
+	testUser  = `{"Name": "User", "Docs": "", "Fields": [{"Name": "Name", "Docs": "", "Typewords": ["string"]}, {"Name": "Kind", "Docs": "", "Typewords": ["Kind"]}]}`
+	testKind  = `{"Name": "Kind", "Docs": "", "Values": [{"Name": "A", "Value": "a", "Docs": ""}, {"Name": "B", "Value": "b", "Docs": ""}]}`
+)
+
+func TestDiff(t *testing.T) {
+	base := testDoc(testFnGet+", "+testFnPut, testUser, testKind)
+	replace := func(old, new string) string {
+		if !strings.Contains(base, old) {
+			t.Fatalf("%q not in base sherpadoc", old)
+		}
+		return strings.Replace(base, old, new, -1)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		new     string
+		changes []string
+	}{
+		{"same", Options{}, base, nil},
+		{
+			"function removed", Options{},
+			testDoc(testFnGet, testUser, testKind),
+			[]string{"breaking: function Put: removed"},
+		},
+		{
+			"function added", Options{},
+			testDoc(testFnGet+", "+testFnPut+`, {"Name": "Del", "Docs": "", "Params": [], "Returns": []}`, testUser, testKind),
+			[]string{"compatible: function Del: added"},
+		},
+		{
+			"param widened", Options{},
+			replace(`"Typewords": ["int32"]`, `"Typewords": ["int64"]`),
+			[]string{"compatible: function Get, param id: type changed from int32 to int64"},
+		},
+		{
+			"param narrowed", Options{},
+			replace(`"Typewords": ["int32"]`, `"Typewords": ["int16"]`),
+			[]string{"breaking: function Get, param id: type changed from int32 to int16"},
+		},
+		{
+			"param made nullable", Options{},
+			replace(`"Typewords": ["int32"]`, `"Typewords": ["nullable", "int32"]`),
+			[]string{"compatible: function Get, param id: made nullable"},
+		},
+		{
+			"result made nullable", Options{},
+			replace(`"Returns": [{"Name": "r0", "Typewords": ["User"]}]`, `"Returns": [{"Name": "r0", "Typewords": ["nullable", "User"]}]`),
+			[]string{"breaking: function Get, return value 0: made nullable"},
+		},
+		{
+			"parameter added", Options{},
+			replace(`"Params": [{"Name": "u", "Typewords": ["User"]}]`, `"Params": [{"Name": "u", "Typewords": ["User"]}, {"Name": "force", "Typewords": ["bool"]}]`),
+			[]string{"breaking: function Put: number of parameters changed from 1 to 2"},
+		},
+		{
+			"field added", Options{},
+			replace(`{"Name": "Kind", "Docs": "", "Typewords": ["Kind"]}`, `{"Name": "Kind", "Docs": "", "Typewords": ["Kind"]}, {"Name": "Age", "Docs": "", "Typewords": ["int32"]}`),
+			[]string{"compatible: struct User, field Age: added"},
+		},
+		{
+			"field removed", Options{},
+			replace(`, {"Name": "Kind", "Docs": "", "Typewords": ["Kind"]}`, ``),
+			[]string{"breaking: struct User, field Kind: removed"},
+		},
+		{
+			// Kind is used in results, clients reject unknown values.
+			"value added in results", Options{},
+			replace(`{"Name": "B", "Value": "b", "Docs": ""}`, `{"Name": "B", "Value": "b", "Docs": ""}, {"Name": "C", "Value": "c", "Docs": ""}`),
+			[]string{"breaking: strings Kind, value C: added"},
+		},
+		{
+			"value changed", Options{},
+			replace(`"Value": "b"`, `"Value": "bb"`),
+			[]string{`breaking: strings Kind, value B: changed from "b" to "bb"`},
+		},
+		{
+			"type renamed", Options{},
+			replace(`User`, `Person`),
+			[]string{"breaking: struct User: renamed to Person"},
+		},
+		{
+			"param renamed", Options{},
+			replace(`{"Name": "id", `, `{"Name": "ident", `),
+			nil,
+		},
+		{
+			"param renamed with named params", Options{NamedParams: true},
+			replace(`{"Name": "id", `, `{"Name": "ident", `),
+			[]string{"breaking: function Get, param id: renamed to ident"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := Diff(strings.NewReader(base), strings.NewReader(test.new), test.opts)
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			var l []string
+			for _, c := range changes {
+				l = append(l, c.String())
+			}
+			if !reflect.DeepEqual(l, test.changes) {
+				t.Fatalf("got changes %q, expected %q", l, test.changes)
+			}
+		})
+	}
+
+	// Changes that depend on the old API.
+	otherTests := []struct {
+		name     string
+		old, new string
+		changes  []string
+	}{
+		{
+			// Kind is only used in parameters, the server accepts the new value.
+			"value added in params only",
+			testDoc(testFnPut, testUser, testKind),
+			testDoc(testFnPut, testUser, strings.Replace(testKind, `"Docs": ""}]`, `"Docs": ""}, {"Name": "C", "Value": "c", "Docs": ""}]`, 1)),
+			[]string{"compatible: strings Kind, value C: added"},
+		},
+		{
+			// Structs without fields have nothing to compare, they are not matched as renames.
+			"empty struct replaced",
+			testDoc(testFnGet, testUser+`, {"Name": "Empty", "Docs": "", "Fields": []}`, testKind),
+			testDoc(testFnGet, testUser+`, {"Name": "Void", "Docs": "", "Fields": []}`, testKind),
+			[]string{"breaking: struct Empty: removed", "compatible: struct Void: added"},
+		},
+	}
+	for _, test := range otherTests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := Diff(strings.NewReader(test.old), strings.NewReader(test.new), Options{})
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			var l []string
+			for _, c := range changes {
+				l = append(l, c.String())
+			}
+			if !reflect.DeepEqual(l, test.changes) {
+				t.Fatalf("got changes %q, expected %q", l, test.changes)
+			}
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		dst, src []string
+		accepts  bool
+	}{
+		{[]string{"int64"}, []string{"int32"}, true},
+		{[]string{"int32"}, []string{"int64"}, false},
+		{[]string{"int64"}, []string{"uint32"}, true},
+		{[]string{"uint64"}, []string{"int8"}, false},
+		{[]string{"float64"}, []string{"float32"}, true},
+		{[]string{"float32"}, []string{"float64"}, false},
+		{[]string{"nullable", "string"}, []string{"string"}, true},
+		{[]string{"string"}, []string{"nullable", "string"}, false},
+		{[]string{"[]", "int64"}, []string{"[]", "int16"}, true},
+		{[]string{"{}", "int16"}, []string{"{}", "int64"}, false},
+		{[]string{"any"}, []string{"User"}, true},
+		{[]string{"User"}, []string{"any"}, false},
+	}
+	for _, test := range tests {
+		if got := accepts(test.dst, test.src); got != test.accepts {
+			t.Errorf("accepts(%v, %v) = %v, expected %v", test.dst, test.src, got, test.accepts)
+		}
+	}
+}
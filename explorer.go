@@ -0,0 +1,384 @@
+package sherpats
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// GenerateExplorer reads sherpadoc from in and writes a single HTML page to out
+// with a form for each function of the API. Submitting a form calls the function
+// through the generated client and shows the result or the error.
+//
+// The page loads the generated client, compiled to JavaScript, from clientURL.
+// If opts.Namespace is set, the client is loaded as a plain script and the
+// namespace is looked up as global variable. Otherwise the client is imported
+// as an ES module. Opts should be the options used to generate the client.
+func GenerateExplorer(in io.Reader, out io.Writer, clientURL string, opts Options) (retErr error) {
+	defer recoverGenError(&retErr)
+
+	opts.check()
+	doc := parseSherpadoc(in, opts)
+	names := resolveNames(&doc, opts)
+	methods := map[string]string{}
+	for fn, name := range names.methods {
+		methods[fn.Name] = name
+	}
+
+	var clientScript, apiImport string
+	if opts.Namespace != "" {
+		clientScript = fmt.Sprintf(`<script src="%s"></script>`, html.EscapeString(clientURL))
+		apiImport = fmt.Sprintf("const api = window[%s]", mustMarshalJSON(opts.Namespace))
+	} else {
+		apiImport = fmt.Sprintf("import * as api from %s", mustMarshalJSON(clientURL))
+	}
+
+	title := doc.Name
+	if title == "" {
+		title = "API"
+	}
+
+	r := strings.NewReplacer(
+		"EXPLORER_TITLE", html.EscapeString(title),
+		"EXPLORER_CLIENT_SCRIPT", clientScript,
+		"EXPLORER_API_IMPORT", apiImport,
+		"EXPLORER_SHERPADOC", mustMarshalJSON(doc),
+		"EXPLORER_METHODS", mustMarshalJSON(methods),
+		"EXPLORER_OPTIONS", mustMarshalJSON(map[string]interface{}{"bigint": opts.BigInt, "timestamp": timestampMode(opts), "namedParams": opts.NamedParams}),
+	)
+	bout := bufio.NewWriter(out)
+	if _, err := r.WriteString(bout, explorerHTML); err != nil {
+		panic(genError{err})
+	}
+	if err := bout.Flush(); err != nil {
+		panic(genError{err})
+	}
+	return nil
+}
+
+// The explorer builds its forms at runtime from the embedded sherpadoc. Values
+// are passed to the client as the generated TypeScript types expect them, the
+// client does the verification.
+const explorerHTML = `<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<meta name="viewport" content="width=device-width, initial-scale=1" />
+		<title>EXPLORER_TITLE - API explorer</title>
+		<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+.function { border: 1px solid #ccc; border-radius: 4px; padding: .5em 1em; margin-bottom: 1em; }
+.function h3 { font-family: monospace; font-size: 1.1em; }
+.docs { white-space: pre-wrap; color: #555; }
+.editor { margin-left: 1em; }
+.field { margin: .25em 0; }
+.field > label { font-family: monospace; margin-right: .5em; }
+.result { white-space: pre-wrap; font-family: monospace; background: #f4f4f4; padding: .5em; }
+.error { white-space: pre-wrap; font-family: monospace; background: #fdd; padding: .5em; }
+		</style>
+		EXPLORER_CLIENT_SCRIPT
+	</head>
+	<body>
+		<noscript>JavaScript is required for the API explorer.</noscript>
+		<script type="module">
+EXPLORER_API_IMPORT
+
+const doc = EXPLORER_SHERPADOC
+
+// Function names to the names of the methods on the client, for functions renamed in the client.
+const methods = EXPLORER_METHODS
+
+// Options the client was generated with that change the types of values.
+const options = EXPLORER_OPTIONS
+
+const types = {}
+const gatherTypes = (sec) => {
+	for (const t of sec.Structs || []) {
+		types[t.Name] = {kind: 'struct', t: t}
+	}
+	for (const t of sec.Ints || []) {
+		types[t.Name] = {kind: 'ints', t: t}
+	}
+	for (const t of sec.Strings || []) {
+		types[t.Name] = {kind: 'strings', t: t}
+	}
+	for (const s of sec.Sections || []) {
+		gatherTypes(s)
+	}
+}
+gatherTypes(doc)
+
+const dom = (tag, attrs, ...children) => {
+	const e = document.createElement(tag)
+	for (const k in attrs || {}) {
+		if (k.startsWith('on')) {
+			e.addEventListener(k.substring(2), attrs[k])
+		} else {
+			e.setAttribute(k, attrs[k])
+		}
+	}
+	for (const c of children) {
+		if (c !== null && c !== undefined) {
+			e.append(c)
+		}
+	}
+	return e
+}
+
+// editor returns an object with an element for editing a value of typewords, and
+// a function returning the current value. The value function throws on invalid
+// input.
+const editor = (path, typewords) => {
+	const w = typewords[0]
+	const rest = typewords.slice(1)
+
+	const invalid = (msg) => {
+		throw new Error(path + ': ' + msg)
+	}
+
+	switch (w) {
+	case 'nullable': {
+		// Inner editors are created lazily, a struct can reference itself through a nullable.
+		let inner = null
+		const box = dom('span', {class: 'editor'})
+		const isnull = dom('input', {type: 'checkbox'})
+		isnull.checked = true
+		isnull.addEventListener('change', () => {
+			if (!isnull.checked && !inner) {
+				inner = editor(path, rest)
+				box.append(inner.root)
+			}
+			if (inner) {
+				inner.root.style.display = isnull.checked ? 'none' : ''
+			}
+		})
+		return {
+			root: dom('span', {}, dom('label', {}, isnull, ' null'), box),
+			value: () => isnull.checked ? null : inner.value(),
+		}
+	}
+	case '[]': {
+		const items = []
+		const list = dom('div', {class: 'editor'})
+		const add = () => {
+			const item = editor(path + '[' + items.length + ']', rest)
+			const row = dom('div', {class: 'field'}, item.root, ' ', dom('button', {type: 'button', onclick: () => {
+				items.splice(items.indexOf(item), 1)
+				row.remove()
+			}}, 'remove'))
+			items.push(item)
+			list.append(row)
+		}
+		return {
+			root: dom('div', {}, list, dom('button', {type: 'button', onclick: add}, 'add element')),
+			value: () => items.map(item => item.value()),
+		}
+	}
+	case '{}': {
+		const entries = []
+		const list = dom('div', {class: 'editor'})
+		const add = () => {
+			const key = dom('input', {type: 'text', placeholder: 'key'})
+			const entry = {key: key, value: editor(path + '{}', rest)}
+			const row = dom('div', {class: 'field'}, key, ': ', entry.value.root, ' ', dom('button', {type: 'button', onclick: () => {
+				entries.splice(entries.indexOf(entry), 1)
+				row.remove()
+			}}, 'remove'))
+			entries.push(entry)
+			list.append(row)
+		}
+		return {
+			root: dom('div', {}, list, dom('button', {type: 'button', onclick: add}, 'add key')),
+			value: () => {
+				const r = {}
+				for (const e of entries) {
+					r[e.key.value] = e.value.value()
+				}
+				return r
+			},
+		}
+	}
+	case 'bool': {
+		const input = dom('input', {type: 'checkbox'})
+		return {root: input, value: () => input.checked}
+	}
+	case 'int64s':
+	case 'uint64s':
+	case 'int64':
+	case 'uint64':
+		if (options.bigint) {
+			const input = dom('input', {type: 'text', value: '0', pattern: '-?[0-9]+'})
+			return {
+				root: input,
+				value: () => {
+					try {
+						return BigInt(input.value)
+					} catch (err) {
+						invalid('invalid integer ' + JSON.stringify(input.value))
+					}
+				},
+			}
+		} else if (w.endsWith('s')) {
+			const input = dom('input', {type: 'text', value: '0', pattern: '-?[0-9]+'})
+			return {root: input, value: () => input.value}
+		}
+		// Fallthrough for int64 and uint64 as number.
+	case 'int8':
+	case 'uint8':
+	case 'int16':
+	case 'uint16':
+	case 'int32':
+	case 'uint32':
+	case 'float32':
+	case 'float64': {
+		const input = dom('input', {type: 'number', step: w.startsWith('float') ? 'any' : '1', value: '0'})
+		return {
+			root: input,
+			value: () => {
+				const v = parseFloat(input.value)
+				if (isNaN(v)) {
+					invalid('invalid number ' + JSON.stringify(input.value))
+				}
+				return v
+			},
+		}
+	}
+	case 'string': {
+		const input = dom('input', {type: 'text'})
+		return {root: input, value: () => input.value}
+	}
+	case 'timestamp': {
+		// Browsers return the value without time zone, it is interpreted as UTC, not
+		// as local time.
+		const input = dom('input', {type: 'datetime-local', step: '0.001'})
+		return {
+			root: dom('span', {}, input, ' UTC'),
+			value: () => {
+				const d = new Date(input.value + 'Z')
+				if (isNaN(d.getTime())) {
+					invalid('invalid timestamp ' + JSON.stringify(input.value))
+				}
+				if (options.timestamp === 'string') {
+					return d.toISOString()
+				} else if (options.timestamp === 'temporal') {
+					return window.Temporal.Instant.from(d.toISOString())
+				}
+				return d
+			},
+		}
+	}
+	case 'any': {
+		const input = dom('textarea', {rows: '3', cols: '40', placeholder: 'JSON'})
+		return {
+			root: input,
+			value: () => {
+				try {
+					return JSON.parse(input.value || 'null')
+				} catch (err) {
+					invalid('invalid JSON: ' + err.message)
+				}
+			},
+		}
+	}
+	}
+
+	// Named types mapped to other TypeScript types are edited as their JSON
+	// representation, and converted like the client converts responses.
+	const e = namedEditor(path, w)
+	const hooks = (api.typeHooks || {})[w]
+	if (hooks && hooks.parse) {
+		return {root: e.root, value: () => hooks.parse(e.value())}
+	}
+	return e
+}
+
+const namedEditor = (path, w) => {
+	const nt = types[w]
+	if (!nt) {
+		throw new Error(path + ': unknown type ' + w)
+	}
+	if (nt.kind === 'struct') {
+		const fields = nt.t.Fields.map(f => ({f: f, editor: editor(path + '.' + f.Name, f.Typewords)}))
+		return {
+			root: dom('div', {class: 'editor'}, ...fields.map(e => dom('div', {class: 'field', title: e.f.Docs}, dom('label', {}, e.f.Name), e.editor.root))),
+			value: () => {
+				const r = {}
+				for (const e of fields) {
+					r[e.f.Name] = e.editor.value()
+				}
+				return r
+			},
+		}
+	}
+	const values = nt.t.Values || []
+	if (values.length > 0) {
+		const select = dom('select', {}, ...values.map((v, i) => dom('option', {value: '' + i, title: v.Docs}, v.Name + ' (' + JSON.stringify(v.Value) + ')')))
+		return {root: select, value: () => values[parseInt(select.value)].Value}
+	}
+	if (nt.kind === 'ints') {
+		return editor(path, ['int32'])
+	}
+	return editor(path, ['string'])
+}
+
+const format = (v) => JSON.stringify(v, (k, v) => typeof v === 'bigint' ? v.toString() : v, '\t')
+
+const functionForm = (fn) => {
+	const params = fn.Params.map(p => ({p: p, editor: editor(p.Name, p.Typewords)}))
+	const output = dom('div', {})
+	const call = async () => {
+		output.replaceChildren()
+		let args
+		try {
+			args = params.map(e => e.editor.value())
+		} catch (err) {
+			output.append(dom('div', {class: 'error'}, 'invalid parameters: ' + err.message))
+			return
+		}
+		let client = new api.Client()
+		if (baseURL.value) {
+			client = client.withOptions({baseURL: baseURL.value})
+		}
+		const start = Date.now()
+		try {
+			if (options.namedParams && args.length > 0) {
+				args = [Object.fromEntries(params.map((e, i) => [e.p.Name, args[i]]))]
+			}
+			const result = await client[methods[fn.Name]](...args)
+			output.append(dom('div', {}, 'result, in ' + (Date.now() - start) + 'ms:'), dom('div', {class: 'result'}, format(result)))
+		} catch (err) {
+			const msg = err && err.code ? err.code + ': ' + err.message : '' + (err && err.message || err)
+			output.append(dom('div', {}, 'error, in ' + (Date.now() - start) + 'ms:'), dom('div', {class: 'error'}, msg))
+		}
+	}
+	return dom('form', {class: 'function', onsubmit: (e) => {
+			e.preventDefault()
+			call()
+		}},
+		dom('h3', {}, fn.Name),
+		fn.Docs ? dom('div', {class: 'docs'}, fn.Docs) : null,
+		...params.map(e => dom('div', {class: 'field'}, dom('label', {}, e.p.Name), e.editor.root)),
+		dom('button', {type: 'submit'}, 'call'),
+		output,
+	)
+}
+
+const section = (sec, depth) => dom('div', {},
+	dom(depth === 0 ? 'h1' : 'h2', {}, sec.Name),
+	sec.Docs ? dom('div', {class: 'docs'}, sec.Docs) : null,
+	...(sec.Functions || []).map(fn => functionForm(fn)),
+	...(sec.Sections || []).map(s => section(s, depth + 1)),
+)
+
+const baseURL = dom('input', {type: 'text', size: '60', placeholder: api.defaultBaseURL})
+document.body.append(
+	dom('div', {class: 'field'}, dom('label', {}, 'Base URL'), baseURL),
+	section(doc, 0),
+)
+		</script>
+	</body>
+</html>
+`
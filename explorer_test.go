@@ -0,0 +1,54 @@
+package sherpats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateExplorer(t *testing.T) {
+	doc := testDoc(testFnGet+", "+strings.Replace(testFnPut, `"Put"`, `"withOptions"`, 1), testUser, testKind)
+
+	tests := []struct {
+		name     string
+		opts     Options
+		contains []string
+	}{
+		{
+			"module", Options{},
+			[]string{
+				`<title>Test - API explorer</title>`,
+				`import * as api from "./api.js"`,
+				// Renamed methods of the client are looked up by function name.
+				`{"Get":"Get","withOptions":"withOptions0"}`,
+				`{"bigint":false,"namedParams":false,"timestamp":"date"}`,
+				`"Name":"User"`,
+			},
+		},
+		{
+			"namespace", Options{Namespace: "testapi", Timestamp: "string", NamedParams: true},
+			[]string{
+				`<script src="./api.js"></script>`,
+				`const api = window["testapi"]`,
+				`{"bigint":false,"namedParams":true,"timestamp":"string"}`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := GenerateExplorer(strings.NewReader(doc), &b, "./api.js", test.opts); err != nil {
+				t.Fatalf("generate explorer: %v", err)
+			}
+			for _, s := range test.contains {
+				if !strings.Contains(b.String(), s) {
+					t.Errorf("explorer does not contain %q", s)
+				}
+			}
+		})
+	}
+
+	var b strings.Builder
+	if err := GenerateExplorer(strings.NewReader(`{"SherpadocVersion": 2}`), &b, "./api.js", Options{}); err == nil {
+		t.Fatalf("explorer for bad sherpadoc version did not fail")
+	}
+}
@@ -0,0 +1,279 @@
+package sherpats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mjl-/sherpadoc"
+)
+
+// Finding is a problem in a sherpadoc, as returned by Lint.
+type Finding struct {
+	// "error" for sherpadoc that cannot be used for generating a client, "warning"
+	// otherwise.
+	Severity string
+
+	// JSON path into the sherpadoc, e.g. "$.Sections[0].Structs[1].Fields[2]".
+	Path string
+
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Path, f.Severity, f.Message)
+}
+
+// Lint reads sherpadoc from in and returns all findings. Errors are structural
+// problems, like those found by sherpadoc.Check, which stops at the first
+// problem. Warnings are about API hygiene: missing documentation, unreferenced
+// named types, identifiers that are renamed in the generated code, e.g. because
+// they are TypeScript keywords, use of "any", "[]uint8" without opts.BytesToString,
+// "nullable nullable" and enums without values.
+func Lint(in io.Reader, opts Options) (findings []Finding, retErr error) {
+	defer recoverGenError(&retErr)
+
+	var doc sherpadoc.Section
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		panic(genError{fmt.Errorf("parsing sherpadoc json: %s", err)})
+	}
+
+	l := &linter{
+		opts:         opts,
+		types:        map[string]string{},
+		structFields: map[string][]sherpadoc.Field{},
+		functions:    map[string]string{},
+		referenced:   map[string]bool{},
+	}
+	const sherpadocVersion = 1
+	if doc.SherpadocVersion != sherpadocVersion {
+		l.errorf("$.SherpadocVersion", "unexpected sherpadoc version %d, expected %d", doc.SherpadocVersion, sherpadocVersion)
+	}
+	l.gatherTypes("$", &doc)
+	l.lintSection("$", &doc)
+	l.lintUnreferenced("$", &doc)
+
+	// Last, resolveNames modifies doc.
+	for _, r := range resolveNames(&doc, opts).renames {
+		l.warnf(r.Path, "%s %q is renamed to %q in the generated code: %s", r.Kind, r.Old, r.New, r.Reason)
+	}
+
+	// Our checks should find everything sherpadoc.Check finds, but make sure
+	// broken sherpadoc is never reported as fine.
+	if len(l.errors) == 0 {
+		if err := sherpadoc.Check(&doc); err != nil {
+			l.errorf("$", "%s", err)
+		}
+	}
+	return append(l.errors, l.warnings...), nil
+}
+
+type linter struct {
+	opts         Options
+	types        map[string]string // Type name to path of its definition.
+	structFields map[string][]sherpadoc.Field
+	functions    map[string]string // Function name to path of its definition.
+	referenced   map[string]bool   // Named types referenced from functions, directly or indirectly.
+
+	errors, warnings []Finding
+}
+
+func (l *linter) errorf(path, format string, args ...interface{}) {
+	l.errors = append(l.errors, Finding{"error", path, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(path, format string, args ...interface{}) {
+	l.warnings = append(l.warnings, Finding{"warning", path, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) checkDocs(path, docs, what string) {
+	if strings.TrimSpace(docs) == "" {
+		l.warnf(path, "%s is undocumented", what)
+	}
+}
+
+func (l *linter) markType(path, name string) {
+	if prev, ok := l.types[name]; ok {
+		l.errorf(path, "duplicate type %q, also defined at %s", name, prev)
+		return
+	}
+	l.types[name] = path
+}
+
+func (l *linter) gatherTypes(path string, sec *sherpadoc.Section) {
+	for i, t := range sec.Structs {
+		l.markType(fmt.Sprintf("%s.Structs[%d]", path, i), t.Name)
+		l.structFields[t.Name] = t.Fields
+	}
+	for i, t := range sec.Ints {
+		l.markType(fmt.Sprintf("%s.Ints[%d]", path, i), t.Name)
+	}
+	for i, t := range sec.Strings {
+		l.markType(fmt.Sprintf("%s.Strings[%d]", path, i), t.Name)
+	}
+	for i, subsec := range sec.Sections {
+		l.gatherTypes(fmt.Sprintf("%s.Sections[%d]", path, i), subsec)
+	}
+}
+
+func (l *linter) lintSection(path string, sec *sherpadoc.Section) {
+	for i, fn := range sec.Functions {
+		fpath := fmt.Sprintf("%s.Functions[%d]", path, i)
+		what := fmt.Sprintf("function %q", fn.Name)
+		if prev, ok := l.functions[fn.Name]; ok {
+			l.errorf(fpath, "duplicate function %q, also defined at %s", fn.Name, prev)
+		} else {
+			l.functions[fn.Name] = fpath
+		}
+		l.checkDocs(fpath, fn.Docs, what)
+		l.lintArgs(fpath+".Params", fn.Params, "parameter", what)
+		l.lintArgs(fpath+".Returns", fn.Returns, "return value", what)
+	}
+
+	for i, t := range sec.Structs {
+		tpath := fmt.Sprintf("%s.Structs[%d]", path, i)
+		what := fmt.Sprintf("struct %q", t.Name)
+		l.checkDocs(tpath, t.Docs, what)
+		names := map[string]bool{}
+		for j, f := range t.Fields {
+			fpath := fmt.Sprintf("%s.Fields[%d]", tpath, j)
+			fwhat := fmt.Sprintf("field %q of %s", f.Name, what)
+			if names[f.Name] {
+				l.errorf(fpath, "duplicate %s", fwhat)
+			}
+			names[f.Name] = true
+			l.checkDocs(fpath, f.Docs, fwhat)
+			l.lintTypewords(fpath+".Typewords", f.Typewords, fwhat)
+		}
+	}
+
+	for i, t := range sec.Ints {
+		tpath := fmt.Sprintf("%s.Ints[%d]", path, i)
+		what := fmt.Sprintf("ints %q", t.Name)
+		l.checkDocs(tpath, t.Docs, what)
+		if len(t.Values) == 0 {
+			l.warnf(tpath, "%s has no values, any integer is allowed", what)
+		}
+		names := map[string]bool{}
+		for j, v := range t.Values {
+			vpath := fmt.Sprintf("%s.Values[%d]", tpath, j)
+			vwhat := fmt.Sprintf("value %q of %s", v.Name, what)
+			if names[v.Name] {
+				l.errorf(vpath, "duplicate %s", vwhat)
+			}
+			names[v.Name] = true
+		}
+	}
+
+	for i, t := range sec.Strings {
+		tpath := fmt.Sprintf("%s.Strings[%d]", path, i)
+		what := fmt.Sprintf("strings %q", t.Name)
+		l.checkDocs(tpath, t.Docs, what)
+		if len(t.Values) == 0 {
+			l.warnf(tpath, "%s has no values, any string is allowed", what)
+		}
+		names := map[string]bool{}
+		for j, v := range t.Values {
+			vpath := fmt.Sprintf("%s.Values[%d]", tpath, j)
+			vwhat := fmt.Sprintf("value %q of %s", v.Name, what)
+			if names[v.Name] {
+				l.errorf(vpath, "duplicate %s", vwhat)
+			}
+			names[v.Name] = true
+		}
+	}
+
+	for i, subsec := range sec.Sections {
+		l.lintSection(fmt.Sprintf("%s.Sections[%d]", path, i), subsec)
+	}
+}
+
+func (l *linter) lintArgs(path string, args []sherpadoc.Arg, kind, fnWhat string) {
+	names := map[string]bool{}
+	for i, a := range args {
+		apath := fmt.Sprintf("%s[%d]", path, i)
+		what := fmt.Sprintf("%s %q of %s", kind, a.Name, fnWhat)
+		if names[a.Name] {
+			l.errorf(apath, "duplicate %s", what)
+		}
+		names[a.Name] = true
+		l.lintTypewords(apath+".Typewords", a.Typewords, what)
+		l.markReferenced(a.Typewords)
+	}
+}
+
+// lintTypewords checks the syntax of typewords and that named types exist.
+func (l *linter) lintTypewords(path string, tw []string, what string) {
+	if len(tw) == 0 {
+		l.errorf(path, "missing type for %s", what)
+		return
+	}
+	for i, w := range tw {
+		last := i == len(tw)-1
+		switch w {
+		case "nullable":
+			if i > 0 && tw[i-1] == "nullable" {
+				l.errorf(path, "nullable nullable for %s", what)
+			}
+			if last {
+				l.errorf(path, "missing type after nullable for %s", what)
+			}
+		case "[]", "{}":
+			if w == "[]" && !last && tw[i+1] == "uint8" && !l.opts.BytesToString {
+				l.warnf(path, "[]uint8 for %s is an array of numbers in TypeScript, but a base64 string in JSON, consider -bytes-to-string", what)
+			}
+			if last {
+				l.errorf(path, "missing type after %s for %s", w, what)
+			}
+		case "any", "bool", "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64", "int64s", "uint64s", "float32", "float64", "string", "timestamp":
+			if w == "any" {
+				l.warnf(path, "%s has type any, its values are not checked", what)
+			}
+			if !last {
+				l.errorf(path, "leftover typewords after %s for %s", w, what)
+				return
+			}
+		default:
+			if _, ok := l.types[w]; !ok {
+				l.errorf(path, "unknown type %q for %s", w, what)
+			}
+			if !last {
+				l.errorf(path, "leftover typewords after %s for %s", w, what)
+				return
+			}
+		}
+	}
+}
+
+func (l *linter) markReferenced(tw []string) {
+	for _, w := range tw {
+		if _, ok := l.types[w]; !ok || l.referenced[w] {
+			continue
+		}
+		l.referenced[w] = true
+		for _, f := range l.structFields[w] {
+			l.markReferenced(f.Typewords)
+		}
+	}
+}
+
+func (l *linter) lintUnreferenced(path string, sec *sherpadoc.Section) {
+	check := func(kind string, i int, name string) {
+		if !l.referenced[name] {
+			l.warnf(fmt.Sprintf("%s.%s[%d]", path, kind, i), "type %q is not referenced by any function", name)
+		}
+	}
+	for i, t := range sec.Structs {
+		check("Structs", i, t.Name)
+	}
+	for i, t := range sec.Ints {
+		check("Ints", i, t.Name)
+	}
+	for i, t := range sec.Strings {
+		check("Strings", i, t.Name)
+	}
+	for i, subsec := range sec.Sections {
+		l.lintUnreferenced(fmt.Sprintf("%s.Sections[%d]", path, i), subsec)
+	}
+}
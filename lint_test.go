@@ -0,0 +1,110 @@
+package sherpats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	const (
+		fnGet = `{"Name": "Get", "Docs": "Get returns a user.", "Params": [{"Name": "id", "Typewords": ["int32"]}], "Returns": [{"Name": "r0", "Typewords": ["User"]}]}`
+		user  = `{"Name": "User", "Docs": "User is a user.", "Fields": [{"Name": "Name", "Docs": "Name of user.", "Typewords": ["string"]}, {"Name": "Kind", "Docs": "Kind of user.", "Typewords": ["Kind"]}]}`
+		kind  = `{"Name": "Kind", "Docs": "Kind of user.", "Values": [{"Name": "A", "Value": "a", "Docs": ""}]}`
+	)
+	base := testDoc(fnGet, user, kind)
+	replace := func(old, new string) string {
+		if !strings.Contains(base, old) {
+			t.Fatalf("%q not in base sherpadoc", old)
+		}
+		return strings.Replace(base, old, new, 1)
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		doc      string
+		findings []string
+	}{
+		{"clean", Options{}, base, nil},
+		{
+			"version", Options{},
+			replace(`"SherpadocVersion": 1`, `"SherpadocVersion": 2`),
+			[]string{"$.SherpadocVersion: error: unexpected sherpadoc version 2, expected 1"},
+		},
+		{
+			"undocumented", Options{},
+			replace(`"Docs": "Get returns a user."`, `"Docs": ""`),
+			[]string{`$.Functions[0]: warning: function "Get" is undocumented`},
+		},
+		{
+			"unknown type", Options{},
+			replace(`["int32"]`, `["Nope"]`),
+			[]string{`$.Functions[0].Params[0].Typewords: error: unknown type "Nope" for parameter "id" of function "Get"`},
+		},
+		{
+			"nullable nullable", Options{},
+			replace(`["int32"]`, `["nullable", "nullable", "int32"]`),
+			[]string{`$.Functions[0].Params[0].Typewords: error: nullable nullable for parameter "id" of function "Get"`},
+		},
+		{
+			"missing type", Options{},
+			replace(`["int32"]`, `["[]"]`),
+			[]string{`$.Functions[0].Params[0].Typewords: error: missing type after [] for parameter "id" of function "Get"`},
+		},
+		{
+			"leftover typewords", Options{},
+			replace(`["int32"]`, `["int32", "string"]`),
+			[]string{`$.Functions[0].Params[0].Typewords: error: leftover typewords after int32 for parameter "id" of function "Get"`},
+		},
+		{
+			"any", Options{},
+			replace(`["int32"]`, `["any"]`),
+			[]string{`$.Functions[0].Params[0].Typewords: warning: parameter "id" of function "Get" has type any, its values are not checked`},
+		},
+		{
+			"bytes", Options{},
+			replace(`["int32"]`, `["[]", "uint8"]`),
+			[]string{`$.Functions[0].Params[0].Typewords: warning: []uint8 for parameter "id" of function "Get" is an array of numbers in TypeScript, but a base64 string in JSON, consider -bytes-to-string`},
+		},
+		{"bytes to string", Options{BytesToString: true}, replace(`["int32"]`, `["[]", "uint8"]`), nil},
+		{
+			"duplicate field", Options{},
+			replace(`{"Name": "Kind", "Docs": "Kind of user."`, `{"Name": "Name", "Docs": "Kind of user."`),
+			[]string{`$.Structs[0].Fields[1]: error: duplicate field "Name" of struct "User"`},
+		},
+		{
+			"no values", Options{},
+			replace(`"Values": [{"Name": "A", "Value": "a", "Docs": ""}]`, `"Values": []`),
+			[]string{`$.Strings[0]: warning: strings "Kind" has no values, any string is allowed`},
+		},
+		{
+			"unreferenced", Options{},
+			testDoc(fnGet, user+`, {"Name": "Extra", "Docs": "Extra is unused.", "Fields": []}`, kind),
+			[]string{`$.Structs[1]: warning: type "Extra" is not referenced by any function`},
+		},
+		{
+			"renamed", Options{},
+			strings.Replace(replace(`{"Name": "id", `, `{"Name": "in", `), `"User"`, `"class"`, -1),
+			[]string{
+				`$.Structs[0]: warning: type "class" is renamed to "class0" in the generated code: typescript keyword`,
+				`$.Functions[0].Params[0]: warning: parameter "in" is renamed to "in0" in the generated code: reserved word`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings, err := Lint(strings.NewReader(test.doc), test.opts)
+			if err != nil {
+				t.Fatalf("lint: %v", err)
+			}
+			var l []string
+			for _, f := range findings {
+				l = append(l, f.String())
+			}
+			if !reflect.DeepEqual(l, test.findings) {
+				t.Fatalf("got findings %q, expected %q", l, test.findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,637 @@
+package sherpats
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mjl-/sherpadoc"
+)
+
+// Keywords in Typescript, from https://github.com/microsoft/TypeScript/blob/master/doc/spec.md.
+var keywords = map[string]struct{}{
+	"break":       {},
+	"case":        {},
+	"catch":       {},
+	"class":       {},
+	"const":       {},
+	"continue":    {},
+	"debugger":    {},
+	"default":     {},
+	"delete":      {},
+	"do":          {},
+	"else":        {},
+	"enum":        {},
+	"export":      {},
+	"extends":     {},
+	"false":       {},
+	"finally":     {},
+	"for":         {},
+	"function":    {},
+	"if":          {},
+	"import":      {},
+	"in":          {},
+	"instanceof":  {},
+	"new":         {},
+	"null":        {},
+	"return":      {},
+	"super":       {},
+	"switch":      {},
+	"this":        {},
+	"throw":       {},
+	"true":        {},
+	"try":         {},
+	"typeof":      {},
+	"var":         {},
+	"void":        {},
+	"while":       {},
+	"with":        {},
+	"implements":  {},
+	"interface":   {},
+	"let":         {},
+	"package":     {},
+	"private":     {},
+	"protected":   {},
+	"public":      {},
+	"static":      {},
+	"yield":       {},
+	"any":         {},
+	"boolean":     {},
+	"number":      {},
+	"string":      {},
+	"symbol":      {},
+	"abstract":    {},
+	"as":          {},
+	"async":       {},
+	"await":       {},
+	"constructor": {},
+	"declare":     {},
+	"from":        {},
+	"get":         {},
+	"is":          {},
+	"module":      {},
+	"namespace":   {},
+	"of":          {},
+	"require":     {},
+	"set":         {},
+	"type":        {},
+}
+
+// Reserved words in JavaScript strict mode and modules. They cannot be used as
+// parameter names. Other keywords in the list above, like "type" or "from", are
+// only keywords in some contexts.
+var reservedWords = map[string]struct{}{
+	"break":      {},
+	"case":       {},
+	"catch":      {},
+	"class":      {},
+	"const":      {},
+	"continue":   {},
+	"debugger":   {},
+	"default":    {},
+	"delete":     {},
+	"do":         {},
+	"else":       {},
+	"enum":       {},
+	"export":     {},
+	"extends":    {},
+	"false":      {},
+	"finally":    {},
+	"for":        {},
+	"function":   {},
+	"if":         {},
+	"import":     {},
+	"in":         {},
+	"instanceof": {},
+	"new":        {},
+	"null":       {},
+	"return":     {},
+	"super":      {},
+	"switch":     {},
+	"this":       {},
+	"throw":      {},
+	"true":       {},
+	"try":        {},
+	"typeof":     {},
+	"var":        {},
+	"void":       {},
+	"while":      {},
+	"with":       {},
+	"implements": {},
+	"interface":  {},
+	"let":        {},
+	"package":    {},
+	"private":    {},
+	"protected":  {},
+	"public":     {},
+	"static":     {},
+	"yield":      {},
+	"await":      {},
+	"arguments":  {},
+	"eval":       {},
+}
+
+// Names that cannot be used for named types, because they are predefined types,
+// or because the generated code declares or uses them at the top-level of the
+// module, with any options. See isModuleName.
+var moduleNames = map[string]struct{}{
+	// Predefined types not in keywords.
+	"never":     {},
+	"object":    {},
+	"unknown":   {},
+	"undefined": {},
+	"bigint":    {},
+
+	// Declared in the generated code.
+	"defaultBaseURL":         {},
+	"supportedSherpaVersion": {},
+	"Section":                {},
+	"Function":               {},
+	"Arg":                    {},
+	"Struct":                 {},
+	"Field":                  {},
+	"Ints":                   {},
+	"Strings":                {},
+	"NamedType":              {},
+	"TypenameMap":            {},
+	"verifyArg":              {},
+	"parse":                  {},
+	"verifier":               {},
+	"ClientOptions":          {},
+	"AuthState":              {},
+	"ClientConfig":           {},
+	"structTypes":            {},
+	"stringsTypes":           {},
+	"intsTypes":              {},
+	"parser":                 {},
+	"verifyAll":              {},
+	"VerifyIssue":            {},
+	"verifyAbort":            {},
+	"gotString":              {},
+	"verifyCheck":            {},
+	"verifyAllCheck":         {},
+	"verifyParams":           {},
+	"verifyResult":           {},
+	"resultIssues":           {},
+	"defaultOptions":         {},
+	"Client":                 {},
+	"types":                  {},
+	"typeHooks":              {},
+	"TypeHooks":              {},
+	"safeParser":             {},
+	"SafeParseResult":        {},
+	"clone":                  {},
+	"equal":                  {},
+
+	// Globals used by the generated code.
+	"Array":          {},
+	"BigInt":         {},
+	"Date":           {},
+	"Error":          {},
+	"JSON":           {},
+	"Math":           {},
+	"Number":         {},
+	"Object":         {},
+	"Partial":        {},
+	"Promise":        {},
+	"Readonly":       {},
+	"ReadonlyArray":  {},
+	"Record":         {},
+	"RegExp":         {},
+	"Temporal":       {},
+	"XMLHttpRequest": {},
+	"console":        {},
+	"globalThis":     {},
+	"location":       {},
+	"setTimeout":     {},
+	"window":         {},
+}
+
+// Members of the generated Client class, function names cannot be used as method
+// names.
+var clientMembers = map[string]struct{}{
+	"constructor":   {},
+	"baseURL":       {},
+	"authState":     {},
+	"options":       {},
+	"withAuthToken": {},
+	"withOptions":   {},
+}
+
+// Local variables in generated functions, and top-level names used in their
+// bodies, parameters cannot have these names. See isFunctionLocal.
+var functionLocals = map[string]struct{}{
+	"fn":                {},
+	"paramTypes":        {},
+	"returnTypes":       {},
+	"params":            {},
+	"clientConfig":      {},
+	"_sherpaCall":       {},
+	"_sherpaCallConfig": {},
+}
+
+// sherpaPrefix starts names of internal helpers in the generated code. Names
+// with this prefix are reserved, so helpers can be added without affecting the
+// names of types, functions and parameters from the API.
+const sherpaPrefix = "_sherpa"
+
+// isModuleName returns whether name is declared or used at the top-level of the
+// module generated with any options. Names are reserved regardless of options,
+// so the names of types don't depend on the options.
+func isModuleName(name string) bool {
+	_, ok := moduleNames[name]
+	return ok || strings.HasPrefix(name, sherpaPrefix)
+}
+
+// isFunctionLocal returns whether name is used in the body of functions
+// generated with opts for doc.
+func isFunctionLocal(doc *sherpadoc.Section, name string, opts Options) bool {
+	if _, ok := functionLocals[name]; ok || strings.HasPrefix(name, sherpaPrefix) {
+		return true
+	}
+	// With lazy runtime types, functions have a local table with the constants of
+	// the types they reference.
+	if runtimeTypesMode(opts) == "lazy" {
+		return name == "types" || strings.HasPrefix(name, "type") && hasNamedType(doc, strings.TrimPrefix(name, "type"))
+	}
+	return false
+}
+
+// Rename is a change of an identifier from the sherpadoc for use in the
+// generated TypeScript code.
+type Rename struct {
+	Path   string // JSON path in the sherpadoc, e.g. "$.Sections[0].Structs[1]".
+	Kind   string // "type", "function", "standalone function", "parameter" or "generated name".
+	Old    string
+	New    string
+	Reason string
+}
+
+func (r Rename) String() string {
+	return fmt.Sprintf("%s %s renamed to %s: %s", r.Kind, r.Old, r.New, r.Reason)
+}
+
+// tsNames holds the TypeScript names for functions and parameters, after
+// resolveNames.
+type tsNames struct {
+	types   map[string]string // Renamed types, from old to new name.
+	methods map[*sherpadoc.Function]string
+	params  map[*sherpadoc.Function][]string
+
+	// Names of standalone functions, only with Options.StandaloneFunctions.
+	functions map[*sherpadoc.Function]string
+
+	// Names generated for named types, after resolveCompanions.
+	companions map[companionKey]string
+
+	renames []Rename
+}
+
+// resolveNames determines names that can be used in the generated code for all
+// types, functions and parameters in doc. Named types are renamed in doc itself,
+// including references to them, so all generated code, including the runtime
+// type information, consistently uses the new name. Function and parameter names
+// are returned, function names are still needed for calling the API. Enum
+// values and struct fields don't need renaming, see propertyName. Names of
+// standalone functions are only resolved with opts.StandaloneFunctions.
+func resolveNames(doc *sherpadoc.Section, opts Options) tsNames {
+	names := tsNames{
+		types:     map[string]string{},
+		methods:   map[*sherpadoc.Function]string{},
+		params:    map[*sherpadoc.Function][]string{},
+		functions: map[*sherpadoc.Function]string{},
+	}
+
+	// New names must not clash with names of other types and functions that we keep.
+	taken := map[string]bool{}
+	functionNames := map[string]bool{}
+	var gather func(sec *sherpadoc.Section)
+	gather = func(sec *sherpadoc.Section) {
+		for _, fn := range sec.Functions {
+			functionNames[fn.Name] = true
+		}
+		for _, t := range sec.Structs {
+			taken[t.Name] = true
+		}
+		for _, t := range sec.Ints {
+			taken[t.Name] = true
+		}
+		for _, t := range sec.Strings {
+			taken[t.Name] = true
+		}
+		for _, subsec := range sec.Sections {
+			gather(subsec)
+		}
+	}
+	gather(doc)
+
+	typeNames := names.types
+	renameType := func(path, name string) string {
+		var reason string
+		if _, ok := keywords[name]; ok {
+			reason = "typescript keyword"
+		} else if isModuleName(name) {
+			reason = "name used by generated code"
+		} else {
+			return name
+		}
+		nname := uniqueName(name, func(s string) bool {
+			_, kw := keywords[s]
+			return kw || isModuleName(s) || taken[s]
+		})
+		taken[nname] = true
+		typeNames[name] = nname
+		names.renames = append(names.renames, Rename{path, "type", name, nname, reason})
+		return nname
+	}
+
+	var renameTypes func(path string, sec *sherpadoc.Section)
+	renameTypes = func(path string, sec *sherpadoc.Section) {
+		for i := range sec.Structs {
+			sec.Structs[i].Name = renameType(fmt.Sprintf("%s.Structs[%d]", path, i), sec.Structs[i].Name)
+		}
+		for i := range sec.Ints {
+			sec.Ints[i].Name = renameType(fmt.Sprintf("%s.Ints[%d]", path, i), sec.Ints[i].Name)
+		}
+		for i := range sec.Strings {
+			sec.Strings[i].Name = renameType(fmt.Sprintf("%s.Strings[%d]", path, i), sec.Strings[i].Name)
+		}
+		for i, subsec := range sec.Sections {
+			renameTypes(fmt.Sprintf("%s.Sections[%d]", path, i), subsec)
+		}
+	}
+	renameTypes("$", doc)
+
+	renameTypewords := func(tw []string) {
+		for i, w := range tw {
+			if nw, ok := typeNames[w]; ok {
+				tw[i] = nw
+			}
+		}
+	}
+	var renameRefs func(path string, sec *sherpadoc.Section)
+	renameRefs = func(path string, sec *sherpadoc.Section) {
+		for i, fn := range sec.Functions {
+			fpath := fmt.Sprintf("%s.Functions[%d]", path, i)
+			names.methods[fn] = fn.Name
+			if _, ok := clientMembers[fn.Name]; ok {
+				nname := uniqueName(fn.Name, func(s string) bool {
+					_, ok := clientMembers[s]
+					return ok || functionNames[s]
+				})
+				functionNames[nname] = true
+				names.methods[fn] = nname
+				names.renames = append(names.renames, Rename{fpath, "function", fn.Name, nname, "name used by generated client"})
+			}
+
+			taken := map[string]bool{}
+			for _, p := range fn.Params {
+				taken[p.Name] = true
+			}
+			var params []string
+			for j, p := range fn.Params {
+				name := p.Name
+				var reason string
+				if opts.NamedParams {
+					// Parameters are properties, no renames needed.
+				} else if _, ok := reservedWords[name]; ok {
+					reason = "reserved word"
+				} else if isFunctionLocal(doc, name, opts) {
+					reason = "name used by generated function"
+				}
+				if reason != "" {
+					name = uniqueName(name, func(s string) bool {
+						_, rw := reservedWords[s]
+						return rw || isFunctionLocal(doc, s, opts) || taken[s]
+					})
+					taken[name] = true
+					names.renames = append(names.renames, Rename{fmt.Sprintf("%s.Params[%d]", fpath, j), "parameter", p.Name, name, reason})
+				}
+				params = append(params, name)
+				renameTypewords(p.Typewords)
+			}
+			names.params[fn] = params
+			for _, r := range fn.Returns {
+				renameTypewords(r.Typewords)
+			}
+		}
+		for _, t := range sec.Structs {
+			for _, f := range t.Fields {
+				renameTypewords(f.Typewords)
+			}
+		}
+		for i, subsec := range sec.Sections {
+			renameRefs(fmt.Sprintf("%s.Sections[%d]", path, i), subsec)
+		}
+	}
+	renameRefs("$", doc)
+
+	if !opts.StandaloneFunctions {
+		return names
+	}
+
+	// Standalone functions are declared next to the types, and names generated for
+	// types, like guards "isKind", enum helpers "KindValues", constructors
+	// "newUser" and split types "UserIn", whether those are generated or not.
+	generated := map[string]bool{}
+	var gatherGenerated func(sec *sherpadoc.Section)
+	gatherGenerated = func(sec *sherpadoc.Section) {
+		add := func(name string, enum bool) {
+			generated[name] = true
+			generated["is"+name] = true
+			generated["type"+name] = true
+			if enum {
+				for _, suffix := range []string{"Values", "Names", "Labels"} {
+					generated[name+suffix] = true
+				}
+				generated[lowerFirst(name)+"FromString"] = true
+			}
+		}
+		for _, t := range sec.Structs {
+			add(t.Name, false)
+			generated["new"+t.Name] = true
+			generated[t.Name+"In"] = true
+			generated[t.Name+"Out"] = true
+		}
+		for _, t := range sec.Ints {
+			add(t.Name, true)
+		}
+		for _, t := range sec.Strings {
+			add(t.Name, true)
+		}
+		for _, subsec := range sec.Sections {
+			gatherGenerated(subsec)
+		}
+	}
+	gatherGenerated(doc)
+	taken = map[string]bool{}
+	for name := range functionNames {
+		taken[name] = true
+	}
+	var renameFunctions func(path string, sec *sherpadoc.Section)
+	renameFunctions = func(path string, sec *sherpadoc.Section) {
+		for i, fn := range sec.Functions {
+			var reason string
+			if _, ok := reservedWords[fn.Name]; ok {
+				reason = "reserved word"
+			} else if _, ok := keywords[fn.Name]; ok {
+				reason = "typescript keyword"
+			} else if isModuleName(fn.Name) || generated[fn.Name] {
+				reason = "name used by generated code"
+			}
+			names.functions[fn] = fn.Name
+			if reason == "" {
+				continue
+			}
+			nname := uniqueName(fn.Name, func(s string) bool {
+				_, rw := reservedWords[s]
+				_, kw := keywords[s]
+				return rw || kw || isModuleName(s) || generated[s] || taken[s]
+			})
+			taken[nname] = true
+			names.functions[fn] = nname
+			names.renames = append(names.renames, Rename{fmt.Sprintf("%s.Functions[%d]", path, i), "standalone function", fn.Name, nname, reason})
+		}
+		for i, subsec := range sec.Sections {
+			renameFunctions(fmt.Sprintf("%s.Sections[%d]", path, i), subsec)
+		}
+	}
+	renameFunctions("$", doc)
+
+	return names
+}
+
+// uniqueName returns name with the lowest number appended for which taken
+// returns false.
+func uniqueName(name string, taken func(string) bool) string {
+	for i := 0; ; i++ {
+		n := fmt.Sprintf("%s%d", name, i)
+		if !taken(n) {
+			return n
+		}
+	}
+}
+
+// renameReport returns a comment for the generated code describing renames.
+func renameReport(renames []Rename) string {
+	if len(renames) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("// Identifiers renamed in this file:\n")
+	for _, r := range renames {
+		fmt.Fprintf(&b, "// - %s\n", r)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// companionKey identifies a name generated for a named type, e.g. "KindValues"
+// for type "Kind".
+type companionKey struct {
+	typeName string
+	name     string
+}
+
+// companion returns the name in the generated code for name generated for type
+// typeName, as resolved by resolveCompanions.
+func (n tsNames) companion(name, typeName string) string {
+	nname, ok := n.companions[companionKey{typeName, name}]
+	if !ok {
+		panic(fmt.Sprintf("missing name %s for type %s", name, typeName))
+	}
+	return nname
+}
+
+// resolveCompanions determines names for the values and types generated for
+// named types with opts: constructors "newUser", split types "UserIn" and
+// "UserOut" for the structs in splitTypes, enum helpers like "KindValues", guards
+// "isKind" and with lazy runtime types the constants "typeKind". A name that
+// clashes with a type, the generated code, a standalone function or another
+// generated name gets a number appended, and is added to the renames. Type
+// names must already be resolved, and opts must have the renamed type mappings.
+func resolveCompanions(doc *sherpadoc.Section, opts Options, splitTypes map[string]bool, names *tsNames) {
+	names.companions = map[companionKey]string{}
+	taken := map[string]bool{}
+	for _, name := range names.functions {
+		taken[name] = true
+	}
+	// Constants of lazy runtime types are used in function bodies.
+	params := map[string]bool{}
+	for _, l := range names.params {
+		for _, name := range l {
+			params[name] = true
+		}
+	}
+
+	clash := func(name string, local bool) string {
+		if isModuleName(name) {
+			return "name used by generated code"
+		} else if hasNamedType(doc, name) {
+			return "name of a type"
+		} else if taken[name] {
+			return "name used by another generated name or standalone function"
+		} else if local && params[name] {
+			return "name of a parameter"
+		}
+		return ""
+	}
+	var path string
+	add := func(name, typeName string, local bool) {
+		nname := name
+		if reason := clash(name, local); reason != "" {
+			nname = uniqueName(name, func(s string) bool { return clash(s, local) != "" })
+			names.renames = append(names.renames, Rename{path, "generated name", name, nname, reason})
+		}
+		taken[nname] = true
+		names.companions[companionKey{typeName, name}] = nname
+	}
+	addEnum := func(name string, hasValues bool) {
+		if hasValues && findTypeMapping(opts.TypeMappings, name) == nil {
+			if opts.EnumHelpers {
+				for _, suffix := range []string{"Values", "Names", "Labels"} {
+					add(name+suffix, name, false)
+				}
+				add(lowerFirst(name)+"FromString", name, false)
+			} else if enumsMode(opts) == "union" {
+				add(name+"Values", name, false)
+			}
+		}
+	}
+	addAll := func(name string) {
+		if opts.Guards {
+			add("is"+name, name, false)
+		}
+		if runtimeTypesMode(opts) == "lazy" {
+			add("type"+name, name, true)
+		}
+	}
+
+	var resolve func(spath string, sec *sherpadoc.Section)
+	resolve = func(spath string, sec *sherpadoc.Section) {
+		for i, t := range sec.Structs {
+			path = fmt.Sprintf("%s.Structs[%d]", spath, i)
+			if opts.Constructors && findTypeMapping(opts.TypeMappings, t.Name) == nil {
+				add("new"+t.Name, t.Name, false)
+			}
+			if splitTypes[t.Name] {
+				add(t.Name+"In", t.Name, false)
+				add(t.Name+"Out", t.Name, false)
+			}
+			addAll(t.Name)
+		}
+		for i, t := range sec.Ints {
+			path = fmt.Sprintf("%s.Ints[%d]", spath, i)
+			addEnum(t.Name, len(t.Values) > 0)
+			addAll(t.Name)
+		}
+		for i, t := range sec.Strings {
+			path = fmt.Sprintf("%s.Strings[%d]", spath, i)
+			addEnum(t.Name, len(t.Values) > 0)
+			addAll(t.Name)
+		}
+		for i, subsec := range sec.Sections {
+			resolve(fmt.Sprintf("%s.Sections[%d]", spath, i), subsec)
+		}
+	}
+	resolve("$", doc)
+}
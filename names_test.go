@@ -0,0 +1,197 @@
+package sherpats
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/sherpadoc"
+)
+
+func parseTestDoc(t *testing.T, s string) *sherpadoc.Section {
+	t.Helper()
+	var doc sherpadoc.Section
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatalf("parsing sherpadoc: %v", err)
+	}
+	return &doc
+}
+
+func TestResolveNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		doc     string
+		renames []string
+	}{
+		{"none", Options{}, testDoc(testFnGet+", "+testFnPut, testUser, testKind), nil},
+		{
+			"keyword type", Options{},
+			testDoc(strings.Replace(testFnGet, `"User"`, `"class"`, 1), strings.Replace(testUser, `"User"`, `"class"`, 1), testKind),
+			[]string{"type class renamed to class0: typescript keyword"},
+		},
+		{
+			// Names are reserved with any options, so type names don't depend on options.
+			"type clashes with generated code", Options{},
+			testDoc(strings.Replace(testFnGet, `"User"`, `"Record"`, 1), strings.Replace(testUser, `"User"`, `"Record"`, 1), testKind),
+			[]string{"type Record renamed to Record0: name used by generated code"},
+		},
+		{
+			"type clashes with generated code with options", Options{EnumHelpers: true},
+			testDoc(strings.Replace(testFnGet, `"User"`, `"Record"`, 1), strings.Replace(testUser, `"User"`, `"Record"`, 1), testKind),
+			[]string{"type Record renamed to Record0: name used by generated code"},
+		},
+		{
+			"type clashes with console", Options{},
+			testDoc(strings.Replace(testFnGet, `"User"`, `"console"`, 1), strings.Replace(testUser, `"User"`, `"console"`, 1), testKind),
+			[]string{"type console renamed to console0: name used by generated code"},
+		},
+		{
+			"rename skips taken name", Options{},
+			testDoc(strings.Replace(testFnGet, `"User"`, `"class"`, 1), strings.Replace(testUser, `"User"`, `"class"`, 1)+`, {"Name": "class0", "Docs": "", "Fields": []}`, testKind),
+			[]string{"type class renamed to class1: typescript keyword"},
+		},
+		{
+			"reserved word param", Options{},
+			testDoc(strings.Replace(testFnGet, `"id"`, `"in"`, 1), testUser, testKind),
+			[]string{"parameter in renamed to in0: reserved word"},
+		},
+		{
+			"function local param", Options{},
+			testDoc(strings.Replace(testFnGet, `"id"`, `"fn"`, 1), testUser, testKind),
+			[]string{"parameter fn renamed to fn0: name used by generated function"},
+		},
+		{
+			"named params are not renamed", Options{NamedParams: true},
+			testDoc(strings.Replace(testFnGet, `"id"`, `"in"`, 1), testUser, testKind),
+			nil,
+		},
+		{
+			"lazy types param", Options{RuntimeTypes: "lazy"},
+			testDoc(strings.Replace(testFnGet, `"id"`, `"types"`, 1)+", "+strings.Replace(testFnPut, `"u"`, `"typeUser"`, 1), testUser, testKind),
+			[]string{
+				"parameter types renamed to types0: name used by generated function",
+				"parameter typeUser renamed to typeUser0: name used by generated function",
+			},
+		},
+		{
+			"full types param", Options{},
+			testDoc(strings.Replace(testFnGet, `"id"`, `"types"`, 1)+", "+strings.Replace(testFnPut, `"u"`, `"typeUser"`, 1), testUser, testKind),
+			nil,
+		},
+		{
+			"client member", Options{},
+			testDoc(strings.Replace(testFnPut, `"Put"`, `"withOptions"`, 1), testUser, testKind),
+			[]string{"function withOptions renamed to withOptions0: name used by generated client"},
+		},
+		{
+			"standalone function", Options{StandaloneFunctions: true},
+			testDoc(strings.Replace(testFnGet, `"Get"`, `"delete"`, 1)+", "+strings.Replace(testFnPut, `"Put"`, `"isUser"`, 1), testUser, testKind),
+			[]string{
+				"standalone function delete renamed to delete0: reserved word",
+				"standalone function isUser renamed to isUser0: name used by generated code",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names := resolveNames(parseTestDoc(t, test.doc), test.opts)
+			var l []string
+			for _, r := range names.renames {
+				l = append(l, r.String())
+			}
+			if !reflect.DeepEqual(l, test.renames) {
+				t.Fatalf("got renames %q, expected %q", l, test.renames)
+			}
+		})
+	}
+}
+
+func TestResolveNamesRefs(t *testing.T) {
+	// References to renamed types are updated too.
+	doc := parseTestDoc(t, testDoc(strings.Replace(testFnGet, `"User"`, `"class"`, 1), strings.Replace(testUser, `"User"`, `"class"`, 1), testKind))
+	resolveNames(doc, Options{})
+	if doc.Structs[0].Name != "class0" {
+		t.Fatalf("got struct name %q, expected class0", doc.Structs[0].Name)
+	}
+	if tw := doc.Functions[0].Returns[0].Typewords; !reflect.DeepEqual(tw, []string{"class0"}) {
+		t.Fatalf("got return typewords %v, expected [class0]", tw)
+	}
+}
+
+func TestResolveCompanions(t *testing.T) {
+	// User has a timestamp, so has In and Out types with SplitTypes.
+	user := `{"Name": "User", "Docs": "", "Fields": [{"Name": "Created", "Docs": "", "Typewords": ["timestamp"]}, {"Name": "Kind", "Docs": "", "Typewords": ["Kind"]}]}`
+	empty := func(name string) string {
+		return `, {"Name": "` + name + `", "Docs": "", "Fields": []}`
+	}
+	doc := testDoc(testFnGet+", "+testFnPut, user+empty("UserIn")+empty("newUser")+empty("KindValues")+empty("isKind"), testKind)
+
+	tests := []struct {
+		name     string
+		opts     Options
+		renames  []string
+		contains []string
+	}{
+		{"none", Options{}, nil, nil},
+		{
+			"split types", Options{SplitTypes: true},
+			[]string{"generated name UserIn renamed to UserIn0: name of a type"},
+			[]string{"export interface UserIn0 {", "export interface UserOut {", "async Put(u: UserIn0): Promise<void>"},
+		},
+		{
+			"constructors", Options{Constructors: true},
+			[]string{"generated name newUser renamed to newUser0: name of a type"},
+			[]string{"export const newUser0 = (partial?: Partial<User>): User => ({Created: new Date(\"0001-01-01T00:00:00Z\"), Kind: Kind.A, ...partial})"},
+		},
+		{
+			"enum helpers", Options{EnumHelpers: true},
+			[]string{"generated name KindValues renamed to KindValues0: name of a type"},
+			[]string{"export const KindValues0: readonly Kind[] = ", "KindValues0.find("},
+		},
+		{
+			"guards", Options{Guards: true},
+			[]string{"generated name isKind renamed to isKind0: name of a type"},
+			[]string{"export const isKind0 = (v: unknown): v is Kind => ", "export const isUserIn = "},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var report, b strings.Builder
+			test.opts.RenameReport = &report
+			if err := Generate(strings.NewReader(doc), &b, "api", test.opts); err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			renames := strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n")
+			if report.Len() == 0 {
+				renames = nil
+			}
+			if !reflect.DeepEqual(renames, test.renames) {
+				t.Fatalf("got renames %q, expected %q", renames, test.renames)
+			}
+			for _, r := range test.renames {
+				if !strings.Contains(b.String(), "// - "+r+"\n") {
+					t.Errorf("rename %q not in generated code", r)
+				}
+			}
+			for _, s := range test.contains {
+				if !strings.Contains(b.String(), s) {
+					t.Errorf("generated code does not contain %q", s)
+				}
+			}
+		})
+	}
+
+	// A standalone function with the name of a generated name is renamed, and
+	// generated names don't use the name of a standalone function.
+	doc = testDoc(testFnGet+", "+strings.Replace(testFnPut, `"Put"`, `"isUser0"`, 1), user+empty("isUser"), testKind)
+	var report, b strings.Builder
+	if err := Generate(strings.NewReader(doc), &b, "api", Options{StandaloneFunctions: true, Guards: true, RenameReport: &report}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	exp := "generated name isUser renamed to isUser1: name of a type\n"
+	if report.String() != exp {
+		t.Fatalf("got renames %q, expected %q", report.String(), exp)
+	}
+}
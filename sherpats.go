@@ -5,83 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/mjl-/sherpadoc"
 )
 
-// Keywords in Typescript, from https://github.com/microsoft/TypeScript/blob/master/doc/spec.md.
-var keywords = map[string]struct{}{
-	"break":       {},
-	"case":        {},
-	"catch":       {},
-	"class":       {},
-	"const":       {},
-	"continue":    {},
-	"debugger":    {},
-	"default":     {},
-	"delete":      {},
-	"do":          {},
-	"else":        {},
-	"enum":        {},
-	"export":      {},
-	"extends":     {},
-	"false":       {},
-	"finally":     {},
-	"for":         {},
-	"function":    {},
-	"if":          {},
-	"import":      {},
-	"in":          {},
-	"instanceof":  {},
-	"new":         {},
-	"null":        {},
-	"return":      {},
-	"super":       {},
-	"switch":      {},
-	"this":        {},
-	"throw":       {},
-	"true":        {},
-	"try":         {},
-	"typeof":      {},
-	"var":         {},
-	"void":        {},
-	"while":       {},
-	"with":        {},
-	"implements":  {},
-	"interface":   {},
-	"let":         {},
-	"package":     {},
-	"private":     {},
-	"protected":   {},
-	"public":      {},
-	"static":      {},
-	"yield":       {},
-	"any":         {},
-	"boolean":     {},
-	"number":      {},
-	"string":      {},
-	"symbol":      {},
-	"abstract":    {},
-	"as":          {},
-	"async":       {},
-	"await":       {},
-	"constructor": {},
-	"declare":     {},
-	"from":        {},
-	"get":         {},
-	"is":          {},
-	"module":      {},
-	"namespace":   {},
-	"of":          {},
-	"require":     {},
-	"set":         {},
-	"type":        {},
+type sherpaType interface {
+	TypescriptType(tc typeContext) string
 }
 
-type sherpaType interface {
-	TypescriptType() string
+// typeContext is the context for generating TypeScript types: the options, and
+// with SplitTypes the structs that have In and Out types, their names, and the
+// direction of the type being generated, "in" or "out".
+type typeContext struct {
+	Options
+	splitTypes map[string]bool
+	names      tsNames
+	direction  string
 }
 
 // baseType can be one of: "any", "int16", etc
@@ -109,21 +49,50 @@ type identType struct {
 	Name string
 }
 
-func (t baseType) TypescriptType() string {
+func (t baseType) TypescriptType(tc typeContext) string {
 	switch t.Name {
 	case "bool":
 		return "boolean"
 	case "timestamp":
-		return "Date"
-	case "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64", "float32", "float64":
+		// Parameters with In types can have timestamps as string too.
+		var orString string
+		if tc.direction == "in" {
+			orString = " | string"
+		}
+		switch tc.Timestamp {
+		case "string":
+			return "string"
+		case "temporal":
+			return "Temporal.Instant" + orString
+		}
+		return "Date" + orString
+	case "int64", "uint64":
+		if tc.BigInt {
+			return "bigint"
+		}
+		return "number"
+	case "int8", "uint8", "int16", "uint16", "int32", "uint32", "float32", "float64":
 		return "number"
 	case "int64s", "uint64s":
+		if tc.BigInt {
+			return "bigint"
+		}
 		return "string"
+	case "any":
+		return anyType(tc.Options)
 	default:
 		return t.Name
 	}
 }
 
+// anyType returns the TypeScript type for values of any type.
+func anyType(opts Options) string {
+	if opts.Unknown {
+		return "unknown"
+	}
+	return "any"
+}
+
 func isBaseOrIdent(t sherpaType) bool {
 	if _, ok := t.(baseType); ok {
 		return true
@@ -134,30 +103,136 @@ func isBaseOrIdent(t sherpaType) bool {
 	return false
 }
 
-func (t nullableType) TypescriptType() string {
+func (t nullableType) TypescriptType(tc typeContext) string {
 	if isBaseOrIdent(t.Type) {
-		return t.Type.TypescriptType() + " | null"
+		return t.Type.TypescriptType(tc) + " | null"
 	}
-	return "(" + t.Type.TypescriptType() + ") | null"
+	return "(" + t.Type.TypescriptType(tc) + ") | null"
 }
 
-func (t arrayType) TypescriptType() string {
-	if isBaseOrIdent(t.Type) {
-		return t.Type.TypescriptType() + "[] | null"
+func (t arrayType) TypescriptType(tc typeContext) string {
+	if isReadonly(tc) {
+		return "ReadonlyArray<" + t.Type.TypescriptType(tc) + "> | null"
+	}
+	if s := t.Type.TypescriptType(tc); isBaseOrIdent(t.Type) && !strings.Contains(s, " | ") {
+		return s + "[] | null"
 	}
-	return "(" + t.Type.TypescriptType() + ")[] | null"
+	return "(" + t.Type.TypescriptType(tc) + ")[] | null"
 }
 
-func (t objectType) TypescriptType() string {
-	return fmt.Sprintf("{ [key: string]: %s }", t.Value.TypescriptType())
+func (t objectType) TypescriptType(tc typeContext) string {
+	if isReadonly(tc) {
+		return fmt.Sprintf("Readonly<Record<string, %s>>", t.Value.TypescriptType(tc))
+	}
+	return fmt.Sprintf("{ [key: string]: %s }", t.Value.TypescriptType(tc))
 }
 
-func (t identType) TypescriptType() string {
+func (t identType) TypescriptType(tc typeContext) string {
+	if m := findTypeMapping(tc.TypeMappings, t.Name); m != nil && m.Type != "" {
+		return m.Type
+	}
+	if tc.splitTypes[t.Name] {
+		switch tc.direction {
+		case "in":
+			return tc.names.companion(t.Name+"In", t.Name)
+		case "out":
+			return tc.names.companion(t.Name+"Out", t.Name)
+		}
+	}
 	return t.Name
 }
 
+// directionContext returns tc for generating In or Out types, for direction
+// "in" or "out", if SplitTypes is set.
+func directionContext(tc typeContext, direction string) typeContext {
+	if tc.SplitTypes {
+		tc.direction = direction
+	}
+	return tc
+}
+
+// isReadonly returns whether readonly types are generated. With SplitTypes, In
+// types are mutable.
+func isReadonly(tc typeContext) bool {
+	return tc.Readonly && tc.direction != "in"
+}
+
 type genError struct{ error }
 
+// check verifies the options are valid, raising a genError if not.
+func (opts Options) check() {
+	switch opts.Timestamp {
+	case "", "date", "string", "temporal":
+	default:
+		panic(genError{fmt.Errorf("unknown timestamp representation %q, must be date, string or temporal", opts.Timestamp)})
+	}
+	switch opts.RuntimeTypes {
+	case "", "full":
+	case "lazy":
+		if opts.CloneEqual {
+			panic(genError{fmt.Errorf("clone and equal require the table with all types, use runtime types full")})
+		}
+	case "none":
+		if opts.BigInt {
+			panic(genError{fmt.Errorf("bigint requires runtime types")})
+		}
+		if timestampMode(opts) != "string" {
+			panic(genError{fmt.Errorf("timestamps as %s require runtime types, use timestamps as string", timestampMode(opts))})
+		}
+		if opts.CompiledChecks {
+			panic(genError{fmt.Errorf("compiled checks require runtime types")})
+		}
+		if opts.CloneEqual {
+			panic(genError{fmt.Errorf("clone and equal require runtime types")})
+		}
+		if opts.Guards {
+			panic(genError{fmt.Errorf("guards require runtime types")})
+		}
+		if opts.SafeParser {
+			panic(genError{fmt.Errorf("safe parser requires runtime types")})
+		}
+		if opts.SplitTypes {
+			panic(genError{fmt.Errorf("split types require runtime types")})
+		}
+		for _, m := range opts.TypeMappings {
+			if m.Parse != "" || m.Serialize != "" {
+				panic(genError{fmt.Errorf("type mapping for %s: parse and serialize require runtime types", m.Name)})
+			}
+		}
+	default:
+		panic(genError{fmt.Errorf("unknown runtime types %q, must be full, lazy or none", opts.RuntimeTypes)})
+	}
+	switch opts.Enums {
+	case "", "enum", "union", "const-object":
+	default:
+		panic(genError{fmt.Errorf("unknown enums representation %q, must be enum, union or const-object", opts.Enums)})
+	}
+	switch opts.Results {
+	case "", "tuple", "labeled-tuple", "object":
+	default:
+		panic(genError{fmt.Errorf("unknown results representation %q, must be tuple, labeled-tuple or object", opts.Results)})
+	}
+	for _, m := range opts.TypeMappings {
+		if m.Import != "" && opts.Namespace != "" {
+			panic(genError{fmt.Errorf("type mapping for %s: imports cannot be used with a namespace", m.Name)})
+		}
+	}
+}
+
+// recoverGenError recovers from a genError panic, storing it in retErr. Other
+// panics are propagated.
+func recoverGenError(retErr *error) {
+	e := recover()
+	if e == nil {
+		return
+	}
+	g, ok := e.(genError)
+	if !ok {
+		panic(e)
+	}
+	*retErr = error(g)
+}
+
 type Options struct {
 	// If not empty, the generated typescript is wrapped in a namespace. This allows
 	// easy compilation, with "tsc --module none" that uses the generated typescript
@@ -185,6 +260,157 @@ type Options struct {
 	// base64 strings. Having the same types in TypeScript is convenient.
 	// If SlicesNullable is set, the strings are made nullable.
 	BytesToString bool
+
+	// If set, int64 and uint64, and their string-encoded variants int64s and
+	// uint64s, are BigInt in TypeScript instead of number and string, so large
+	// values don't lose precision and can be used for arithmetic. Responses are
+	// parsed such that large integers keep their precision, and values out of range
+	// for the type are rejected. The generated code requires at least ES2020, for
+	// BigInt.
+	BigInt bool
+
+	// Timestamp is the TypeScript representation of timestamps: "date" (default)
+	// for JavaScript Date, "string" for the RFC3339 string as sent by the server,
+	// and "temporal" for Temporal.Instant. Date has only millisecond precision and
+	// no timezone offset. Strings are passed through unchanged, keeping the
+	// precision and offset. Temporal.Instant keeps nanosecond precision, but not the
+	// offset. With "temporal", Temporal must be available as global, e.g. through a
+	// polyfill, also for its TypeScript types.
+	Timestamp string
+
+	// RuntimeTypes is "full" (default) for a table with all named types, used
+	// for verifying values at runtime. With "lazy", there is no table with all
+	// types, each function references only the named types it needs, so bundlers
+	// can leave out the types of functions that are not referenced. Note that
+	// methods of Client are always referenced if Client is. Parser, safeParser and
+	// the type guards reference the types they need too, parse must be called with
+	// the types. With "none", no runtime type information is generated, values
+	// are not verified and not converted, and parser is not generated. This
+	// requires timestamps as string, and is incompatible with BigInt,
+	// CompiledChecks, Guards, SafeParser and type mappings with parse and
+	// serialize functions.
+	RuntimeTypes string
+
+	// NamedParams generates functions that take a single object with the
+	// parameters as properties, named as in the sherpadoc, instead of positional
+	// parameters.
+	NamedParams bool
+
+	// Results is the representation of multiple return values of a function:
+	// "tuple" (default) for a tuple, "labeled-tuple" for a tuple with the
+	// sherpadoc names as labels, or "object" for an object with the names as
+	// properties.
+	Results string
+
+	// StandaloneFunctions generates an exported function for each API function,
+	// in addition to the methods of Client, with a ClientConfig as first
+	// parameter, e.g. a Client. Bundlers can leave out functions that are not
+	// referenced, and with RuntimeTypes "lazy" their runtime types too.
+	StandaloneFunctions bool
+
+	// CompiledChecks generates checks for the fields of structs and the parameters
+	// and results of functions, instead of interpreting their typewords for each
+	// value that is verified. Values are verified the same way, but faster. In the
+	// benchmark in bench/, verifying a list of 10000 structs with 9 fields of
+	// various types with node v22 took 13ms instead of 18ms. Much of the remaining
+	// time is spent on creating the new values, e.g. Date for timestamps. The
+	// generated code becomes larger.
+	CompiledChecks bool
+
+	// Enums is the TypeScript representation of ints and strings types with
+	// values: "enum" (default) for a TypeScript enum, "union" for a union of the
+	// literal values with a frozen array of the values named with suffix "Values",
+	// and "const-object" for an object with the values "as const" and a type
+	// derived from it. Unlike enums, unions and const objects work with
+	// isolatedModules and erasable syntax only, and unions allow using the literal
+	// values directly.
+	Enums string
+
+	// EnumHelpers generates helpers for ints and strings types with values, e.g.
+	// for type Kind: KindValues with all values, KindNames and KindLabels mapping
+	// values to their name and to the first line of their documentation (or name),
+	// and kindFromString returning the value with a string form, or undefined.
+	EnumHelpers bool
+
+	// Guards generates a type guard isFoo for each named type Foo, returning
+	// whether a value is valid for the type, e.g. for data from other sources than
+	// the API. Guards check in-memory values, e.g. with a Date for a timestamp, not
+	// JSON values. For JSON, e.g. from local storage, use parser or safeParser.
+	// Requires runtime types.
+	Guards bool
+
+	// SafeParser generates safeParser, like parser, but returning all issues
+	// with a value instead of throwing an exception for the first. Requires
+	// runtime types.
+	SafeParser bool
+
+	// Constructors generates a function newFoo for each struct Foo, returning a
+	// Foo with the Go zero value for each field, and fields from an optional
+	// partial Foo. Slices are empty arrays, or null with SlicesNullable. Maps are
+	// empty objects, or null with MapsNullable. Fields with a named struct type
+	// get a new value of that struct. Fields with an ints or strings type with
+	// values get the zero value if it is a valid value, and the first value
+	// otherwise. Fields with a type mapped to a type with a parse function are
+	// null.
+	Constructors bool
+
+	// CloneEqual generates generic functions clone and equal for deep copies and
+	// structural comparison of values of named types, driven by the runtime type
+	// information. Requires the table with all types, i.e. RuntimeTypes "full".
+	CloneEqual bool
+
+	// Readonly generates readonly properties for struct fields, ReadonlyArray for
+	// slices, Readonly<Record<...>> for maps and readonly tuples for multiple return
+	// values, e.g. for state stores that require immutable data. Parameters have
+	// the same types, mutable values can be passed as readonly values.
+	Readonly bool
+
+	// Unknown generates "unknown" instead of "any" for the sherpadoc type "any",
+	// for the values passed to parser and safeParser, and in the generated
+	// functions. Values of type unknown must be checked before use, but any value
+	// can be passed as parameter. The runtime library that is included in the
+	// generated code, e.g. the verifier, still uses "any", so lint rules against
+	// "any" must still be suppressed for the generated file.
+	Unknown bool
+
+	// SplitTypes generates types FooIn and FooOut next to struct Foo, if the
+	// fields differ between parameters and results. In types, used for parameters,
+	// have timestamps that can also be RFC3339 strings, optional fields with
+	// NullableOptional, and are never readonly. Out types, used for results, have
+	// nullable fields that are always present, with missing values replaced by
+	// null, and are readonly with Readonly.
+	SplitTypes bool
+
+	// JSDoc generates documentation as /** ... */ JSDoc comments, which editors
+	// show on hover, instead of // comments. Functions get @param and @returns
+	// tags with the types of parameters and return values, and names of types in
+	// documentation become {@link ...} references. Paragraphs starting with
+	// "Deprecated:" or "Experimental:" become @deprecated and @experimental tags.
+	// Documentation of subsections is a // comment before their functions.
+	JSDoc bool
+
+	// WarnDeprecated makes deprecated functions, with a paragraph starting with
+	// "Deprecated:" in their documentation, log a warning with console.warn the
+	// first time they are called, including a stack trace to find the caller.
+	WarnDeprecated bool
+
+	// DocWidth is the maximum length of lines of documentation, excluding
+	// indenting and comment markers. Longer lines are wrapped at spaces. Indented
+	// lines, e.g. code examples, are not wrapped. Zero means no wrapping.
+	DocWidth int
+
+	// TypeMappings make named types from the sherpadoc different TypeScript types,
+	// e.g. a class from a library, see ParseTypeMappings. No TypeScript
+	// declaration is generated for mapped types. Imports cannot be used with
+	// Namespace.
+	TypeMappings []TypeMapping
+
+	// If set, identifiers from the sherpadoc that are renamed in the generated code,
+	// e.g. because they are TypeScript keywords, and generated names for types that
+	// are renamed, e.g. "UserIn" for a struct "User" if there is also a type "UserIn",
+	// are written to RenameReport, one per line. The renames are also listed in a
+	// comment in the generated code.
+	RenameReport io.Writer
 }
 
 // Generate reads sherpadoc from in and writes a typescript file containing a
@@ -192,75 +418,24 @@ type Options struct {
 // baseURL, depending on whether it contains a slash. If it is a package name, the
 // baseURL is created at runtime by adding the packageName to the current location.
 func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options) (retErr error) {
-	defer func() {
-		e := recover()
-		if e == nil {
-			return
-		}
-		g, ok := e.(genError)
-		if !ok {
-			panic(e)
-		}
-		retErr = error(g)
-	}()
+	defer recoverGenError(&retErr)
 
-	var doc sherpadoc.Section
-	err := json.NewDecoder(os.Stdin).Decode(&doc)
-	if err != nil {
-		panic(genError{fmt.Errorf("parsing sherpadoc json: %s", err)})
-	}
+	opts.check()
+	doc := parseSherpadoc(in, opts)
 
-	const sherpadocVersion = 1
-	if doc.SherpadocVersion != sherpadocVersion {
-		panic(genError{fmt.Errorf("unexpected sherpadoc version %d, expected %d", doc.SherpadocVersion, sherpadocVersion)})
-	}
+	// Rename identifiers that cannot be used in TypeScript. Named types are renamed
+	// in doc, before making the copy with types for runtime type checking below.
+	names := resolveNames(&doc, opts)
 
-	if opts.BytesToString {
-		toString := func(tw []string) []string {
-			n := len(tw) - 1
-			for i := 0; i < n; i++ {
-				if tw[i] == "[]" && tw[i+1] == "uint8" {
-					if opts.SlicesNullable && (i == 0 || tw[i-1] != "nullable") {
-						tw[i] = "nullable"
-						tw[i+1] = "string"
-						i++
-					} else {
-						tw[i] = "string"
-						copy(tw[i+1:], tw[i+2:])
-						tw = tw[:len(tw)-1]
-						n--
-					}
-				}
-			}
-			return tw
+	// Type mappings are for names from the sherpadoc, make them refer to the renamed types.
+	opts.TypeMappings = append([]TypeMapping{}, opts.TypeMappings...)
+	for i, m := range opts.TypeMappings {
+		if nname, ok := names.types[m.Name]; ok {
+			opts.TypeMappings[i].Name = nname
 		}
-
-		var bytesToString func(sec *sherpadoc.Section)
-		bytesToString = func(sec *sherpadoc.Section) {
-			for i := range sec.Functions {
-				for j := range sec.Functions[i].Params {
-					sec.Functions[i].Params[j].Typewords = toString(sec.Functions[i].Params[j].Typewords)
-				}
-				for j := range sec.Functions[i].Returns {
-					sec.Functions[i].Returns[j].Typewords = toString(sec.Functions[i].Returns[j].Typewords)
-				}
-			}
-			for i := range sec.Structs {
-				for j := range sec.Structs[i].Fields {
-					sec.Structs[i].Fields[j].Typewords = toString(sec.Structs[i].Fields[j].Typewords)
-				}
-			}
-			for _, s := range sec.Sections {
-				bytesToString(s)
-			}
+		if !hasNamedType(&doc, opts.TypeMappings[i].Name) {
+			panic(genError{fmt.Errorf("type mapping for unknown type %s", m.Name)})
 		}
-		bytesToString(&doc)
-	}
-
-	// Validate the sherpadoc.
-	err = sherpadoc.Check(&doc)
-	if err != nil {
-		panic(genError{err})
 	}
 
 	// Make a copy, the ugly way. We'll strip the documentation out before including
@@ -272,23 +447,117 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 	} else if err := json.Unmarshal(typesbuf, &typesdoc); err != nil {
 		panic(genError{fmt.Errorf("unmarshal sherpadoc for types: %s", err)})
 	}
-	for i := range typesdoc.Structs {
-		typesdoc.Structs[i].Docs = ""
-		for j := range typesdoc.Structs[i].Fields {
-			typesdoc.Structs[i].Fields[j].Docs = ""
+	var stripDocs func(sec *sherpadoc.Section)
+	stripDocs = func(sec *sherpadoc.Section) {
+		for i := range sec.Structs {
+			sec.Structs[i].Docs = ""
+			for j := range sec.Structs[i].Fields {
+				sec.Structs[i].Fields[j].Docs = ""
+			}
+		}
+		for i := range sec.Ints {
+			sec.Ints[i].Docs = ""
+			for j := range sec.Ints[i].Values {
+				sec.Ints[i].Values[j].Docs = ""
+			}
+		}
+		for i := range sec.Strings {
+			sec.Strings[i].Docs = ""
+			for j := range sec.Strings[i].Values {
+				sec.Strings[i].Values[j].Docs = ""
+			}
+		}
+		for _, subsec := range sec.Sections {
+			stripDocs(subsec)
+		}
+	}
+	stripDocs(&typesdoc)
+
+	// Named types by name, for finding the types needed for verifying values of functions.
+	namedTypes := map[string]interface{}{}
+	var gatherNamedTypes func(sec *sherpadoc.Section)
+	gatherNamedTypes = func(sec *sherpadoc.Section) {
+		for _, t := range sec.Structs {
+			namedTypes[t.Name] = t
+		}
+		for _, t := range sec.Ints {
+			namedTypes[t.Name] = t
+		}
+		for _, t := range sec.Strings {
+			namedTypes[t.Name] = t
+		}
+		for _, subsec := range sec.Sections {
+			gatherNamedTypes(subsec)
+		}
+	}
+	gatherNamedTypes(&typesdoc)
+
+	// With SplitTypes, structs get In and Out types if fields differ between
+	// parameters and results, including through fields with structs that have In
+	// and Out types.
+	tc := typeContext{Options: opts}
+	if opts.SplitTypes {
+		tc.splitTypes = map[string]bool{}
+		differs := func(tw []string) bool {
+			for _, w := range tw {
+				if w == "timestamp" && timestampMode(opts) != "string" || tc.splitTypes[w] {
+					return true
+				}
+			}
+			return false
+		}
+		for changed := true; changed; {
+			changed = false
+			for name, t := range namedTypes {
+				st, ok := t.(sherpadoc.Struct)
+				if !ok || tc.splitTypes[name] || findTypeMapping(opts.TypeMappings, name) != nil {
+					continue
+				}
+				for _, f := range st.Fields {
+					if opts.Readonly || isOptional(f.Typewords, opts) || differs(f.Typewords) {
+						tc.splitTypes[name] = true
+						changed = true
+						break
+					}
+				}
+			}
 		}
 	}
-	for i := range typesdoc.Ints {
-		typesdoc.Ints[i].Docs = ""
-		for j := range typesdoc.Ints[i].Values {
-			typesdoc.Ints[i].Values[j].Docs = ""
+
+	// Names generated for types, like "UserIn" and "newUser", are resolved now the
+	// split types are known, completing the renames.
+	resolveCompanions(&doc, opts, tc.splitTypes, &names)
+	tc.names = names
+	if opts.RenameReport != nil {
+		for _, r := range names.renames {
+			if _, err := fmt.Fprintln(opts.RenameReport, r); err != nil {
+				panic(genError{fmt.Errorf("writing rename report: %s", err)})
+			}
 		}
 	}
-	for i := range typesdoc.Strings {
-		typesdoc.Strings[i].Docs = ""
-		for j := range typesdoc.Strings[i].Values {
-			typesdoc.Strings[i].Values[j].Docs = ""
+
+	// Values from parser and safeParser, and from constructors, have the Out types
+	// with SplitTypes.
+	outContext := directionContext(tc, "out")
+
+	// typeConst returns the name of the constant with the runtime type information
+	// for a named type, with lazy runtime types.
+	typeConst := func(name string) string {
+		return names.companion("type"+name, name)
+	}
+
+	// typeTable returns an expression for a TypenameMap with the named types
+	// referenced by typewords: the table with all types, or with lazy runtime
+	// types only the referenced types.
+	typeTable := func(tws [][]string) string {
+		if runtimeTypesMode(opts) != "lazy" {
+			return "types"
+		}
+		var refs []string
+		for _, name := range referencedTypes(namedTypes, tws) {
+			refs = append(refs, fmt.Sprintf("%s: %s", mustMarshalJSON(name), typeConst(name)))
 		}
+		return "{" + strings.Join(refs, ", ") + "}"
 	}
 
 	bout := bufio.NewWriter(out)
@@ -299,8 +568,53 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 		}
 	}
 
+	// xprintJSDoc writes docs as JSDoc comment, followed by tags. Names of types
+	// in docs, except self, are turned into links.
+	xprintJSDoc := func(indent, docs, self string, tags []string) {
+		lines, docTags := splitDocTags(docLines(docs))
+		lines = wrapDocLines(lines, opts.DocWidth)
+		docTags = wrapDocLines(docTags, opts.DocWidth)
+		for i, line := range lines {
+			lines[i] = linkTypes(line, namedTypes, self)
+		}
+		for i, line := range docTags {
+			docTags[i] = linkTypes(line, namedTypes, self)
+		}
+		tags = append(docTags, tags...)
+		if len(lines) > 0 && len(tags) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, tags...)
+		if len(lines) == 0 {
+			return
+		}
+		for i, line := range lines {
+			lines[i] = strings.Replace(line, "*/", "*\\/", -1)
+		}
+		if len(lines) == 1 {
+			xprintf("%s/** %s */\n", indent, lines[0])
+			return
+		}
+		xprintf("%s/**\n", indent)
+		for _, line := range lines {
+			if line == "" {
+				xprintf("%s *\n", indent)
+			} else {
+				xprintf("%s * %s\n", indent, line)
+			}
+		}
+		xprintf("%s */\n", indent)
+	}
+
+	// xprintMultiline writes docs as comment. If always is false and docs are a
+	// single line, nothing is written and the line is returned for
+	// xprintSingleline.
 	xprintMultiline := func(indent, docs string, always bool) []string {
-		lines := docLines(docs)
+		if opts.JSDoc {
+			xprintJSDoc(indent, docs, "", nil)
+			return nil
+		}
+		lines := wrapDocLines(docLines(docs), opts.DocWidth)
 		if len(lines) == 1 && !always {
 			return lines
 		}
@@ -310,6 +624,15 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 		return lines
 	}
 
+	// xprintComment writes a single line comment about generated code.
+	xprintComment := func(format string, args ...interface{}) {
+		if opts.JSDoc {
+			xprintf("/** %s */\n", fmt.Sprintf(format, args...))
+		} else {
+			xprintf("// %s\n", fmt.Sprintf(format, args...))
+		}
+	}
+
 	xprintSingleline := func(lines []string) {
 		if len(lines) != 1 {
 			return
@@ -317,89 +640,252 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 		xprintf("  // %s", lines[0])
 	}
 
-	// Type and function names could be typescript keywords. If they are, give them a different name.
-	typescriptNames := map[string]string{}
-	typescriptName := func(name string, names map[string]string) string {
-		if _, ok := keywords[name]; !ok {
-			return name
+	// xprintTypeDocs writes the documentation for named type name.
+	xprintTypeDocs := func(docs, name string) {
+		if opts.JSDoc {
+			xprintJSDoc("", docs, name, nil)
+		} else {
+			xprintMultiline("", docs, true)
+		}
+	}
+
+	// zeroValue returns a TypeScript expression for the Go zero value of a field
+	// with typewords tw, for Options.Constructors.
+	var zeroValue func(tw []string) string
+	zeroValue = func(tw []string) string {
+		switch tw[0] {
+		case "nullable", "any":
+			return "null"
+		case "[]":
+			if opts.SlicesNullable {
+				return "null"
+			}
+			return "[]"
+		case "{}":
+			if opts.MapsNullable {
+				return "null"
+			}
+			return "{}"
+		case "bool":
+			return "false"
+		case "int8", "uint8", "int16", "uint16", "int32", "uint32", "float32", "float64":
+			return "0"
+		case "int64", "uint64":
+			if opts.BigInt {
+				return "0n"
+			}
+			return "0"
+		case "int64s", "uint64s":
+			if opts.BigInt {
+				return "0n"
+			}
+			return `"0"`
+		case "string":
+			return `""`
+		case "timestamp":
+			switch timestampMode(opts) {
+			case "string":
+				return `"0001-01-01T00:00:00Z"`
+			case "temporal":
+				return `Temporal.Instant.from("0001-01-01T00:00:00Z")`
+			}
+			return `new Date("0001-01-01T00:00:00Z")`
+		}
+
+		// Named type. Mapped types get the zero value of the type from the sherpadoc,
+		// or null if the mapping has a parse function: the parse function may not
+		// accept the zero value, e.g. an empty string for a number type.
+		name := tw[0]
+		var values []enumValue
+		var zero string
+		switch t := namedTypes[name].(type) {
+		case sherpadoc.Ints:
+			zero = "0"
+			for _, v := range t.Values {
+				values = append(values, enumValue{v.Name, fmt.Sprintf("%d", v.Value), v.Docs})
+			}
+		case sherpadoc.Strings:
+			zero = `""`
+			for _, v := range t.Values {
+				values = append(values, enumValue{v.Name, mustMarshalJSON(v.Value), v.Docs})
+			}
+		default:
+			zero = "{}"
 		}
-		n := names[name]
-		if n != "" {
-			return n
+		var value *enumValue
+		for i, v := range values {
+			if v.Literal == zero || value == nil {
+				value = &values[i]
+			}
 		}
-		for i := 0; ; i++ {
-			n = fmt.Sprintf("%s%d", name, i)
-			if _, ok := names[n]; ok {
-				continue
+		if m := findTypeMapping(opts.TypeMappings, name); m != nil {
+			if value != nil {
+				zero = value.Literal
+			}
+			if m.Parse != "" {
+				return "null as any"
 			}
-			names[name] = n
-			return n
+			return zero + " as any"
 		}
+		if zero == "{}" {
+			return names.companion("new"+name, name) + "()"
+		}
+		if value == nil {
+			return zero
+		}
+		return enumValueRefs(name, []enumValue{*value}, opts)
 	}
 
 	structTypes := map[string]bool{}
 	stringsTypes := map[string]bool{}
 	intsTypes := map[string]bool{}
 
+	// generateEnum writes an ints or strings type with values in the form
+	// configured in opts.Enums. The value literals are TypeScript literals.
+	generateEnum := func(name string, values []enumValue) {
+		switch enumsMode(opts) {
+		case "enum":
+			xprintf("export enum %s {\n", name)
+			for _, v := range values {
+				lines := xprintMultiline("\t", v.Docs, false)
+				xprintf("\t%s = %s,", propertyName(v.Name), v.Literal)
+				xprintSingleline(lines)
+				xprintf("\n")
+			}
+			xprintf("}\n\n")
+		case "union":
+			xprintf("export type %s =\n", name)
+			for _, v := range values {
+				lines := xprintMultiline("\t", v.Docs, false)
+				xprintf("\t| %s", v.Literal)
+				xprintSingleline(lines)
+				xprintf("\n")
+			}
+			if !opts.EnumHelpers {
+				xprintf("export const %s: readonly %s[] = Object.freeze([%s])\n", names.companion(name+"Values", name), name, enumValueRefs(name, values, opts))
+			}
+			xprintf("\n")
+		case "const-object":
+			xprintf("export const %s = {\n", name)
+			for _, v := range values {
+				lines := xprintMultiline("\t", v.Docs, false)
+				xprintf("\t%s: %s,", propertyName(v.Name), v.Literal)
+				xprintSingleline(lines)
+				xprintf("\n")
+			}
+			xprintf("} as const\n")
+			xprintf("export type %s = typeof %s[keyof typeof %s]\n\n", name, name, name)
+		}
+
+		if !opts.EnumHelpers {
+			return
+		}
+		valuesName := names.companion(name+"Values", name)
+		xprintComment("Values of %s, in order of definition.", name)
+		xprintf("export const %s: readonly %s[] = Object.freeze([%s])\n", valuesName, name, enumValueRefs(name, values, opts))
+		xprintComment("Names of the values of %s.", name)
+		xprintf("export const %s: Readonly<Record<%s, string>> = Object.freeze({\n", names.companion(name+"Names", name), name)
+		for _, v := range values {
+			xprintf("\t%s: %s,\n", enumValueKey(v), mustMarshalJSON(v.Name))
+		}
+		xprintf("})\n")
+		xprintComment("Labels for the values of %s, the first line of their documentation, or their name.", name)
+		xprintf("export const %s: Readonly<Record<%s, string>> = Object.freeze({\n", names.companion(name+"Labels", name), name)
+		for _, v := range values {
+			label := v.Name
+			if lines := docLines(v.Docs); len(lines) > 0 {
+				label = strings.TrimSpace(lines[0])
+			}
+			xprintf("\t%s: %s,\n", enumValueKey(v), mustMarshalJSON(label))
+		}
+		xprintf("})\n")
+		xprintComment("%s returns the value of %s that is s in string form, or undefined.", names.companion(lowerFirst(name)+"FromString", name), name)
+		xprintf("export const %s = (s: string): %s | undefined => %s.find(v => '' + v === s)\n\n", names.companion(lowerFirst(name)+"FromString", name), name, valuesName)
+	}
+
+	// generateStruct writes an interface for struct t, with types for fields as
+	// configured in o. Fields of Out types are never optional, those of In types
+	// are never readonly.
+	generateStruct := func(name string, t sherpadoc.Struct, tc typeContext) {
+		xprintf("export interface %s {\n", name)
+		for _, f := range t.Fields {
+			lines := xprintMultiline("\t", f.Docs, false)
+			what := fmt.Sprintf("field %s for type %s", f.Name, t.Name)
+			optional := ""
+			if isOptional(f.Typewords, tc.Options) && tc.direction != "out" {
+				optional = "?"
+			}
+			readonly := ""
+			if isReadonly(tc) {
+				readonly = "readonly "
+			}
+			xprintf("\t%s%s%s: %s", readonly, propertyName(f.Name), optional, typescriptType(what, f.Typewords, tc))
+			xprintSingleline(lines)
+			xprintf("\n")
+		}
+		xprintf("}\n\n")
+	}
+
 	var generateTypes func(sec *sherpadoc.Section)
 	generateTypes = func(sec *sherpadoc.Section) {
 		for _, t := range sec.Structs {
 			structTypes[t.Name] = true
-			xprintMultiline("", t.Docs, true)
-			name := typescriptName(t.Name, typescriptNames)
-			xprintf("export interface %s {\n", name)
-			names := map[string]string{}
-			for _, f := range t.Fields {
-				lines := xprintMultiline("", f.Docs, false)
-				what := fmt.Sprintf("field %s for type %s", f.Name, t.Name)
-				optional := ""
-				if opts.NullableOptional && f.Typewords[0] == "nullable" || opts.NullableOptional && (opts.SlicesNullable && f.Typewords[0] == "[]" || opts.MapsNullable && f.Typewords[0] == "{}") {
-					optional = "?"
+			if findTypeMapping(opts.TypeMappings, t.Name) != nil {
+				continue
+			}
+			xprintTypeDocs(t.Docs, t.Name)
+			generateStruct(t.Name, t, tc)
+			if opts.Constructors {
+				var fields []string
+				for _, f := range t.Fields {
+					fields = append(fields, fmt.Sprintf("%s: %s", propertyName(f.Name), zeroValue(f.Typewords)))
 				}
-				xprintf("\t%s%s: %s", typescriptName(f.Name, names), optional, typescriptType(what, f.Typewords))
-				xprintSingleline(lines)
-				xprintf("\n")
+				fields = append(fields, "...partial")
+				cname := names.companion("new"+t.Name, t.Name)
+				xprintComment("%s returns a new %s with Go zero values, and the fields in partial.", cname, t.Name)
+				tname := identType{t.Name}.TypescriptType(outContext)
+				xprintf("export const %s = (partial?: Partial<%s>): %s => ({%s})\n\n", cname, tname, tname, strings.Join(fields, ", "))
+			}
+			if tc.splitTypes[t.Name] {
+				xprintComment("%s is %s as passed in parameters.", names.companion(t.Name+"In", t.Name), t.Name)
+				generateStruct(names.companion(t.Name+"In", t.Name), t, directionContext(tc, "in"))
+				xprintComment("%s is %s as returned in results.", names.companion(t.Name+"Out", t.Name), t.Name)
+				generateStruct(names.companion(t.Name+"Out", t.Name), t, directionContext(tc, "out"))
 			}
-			xprintf("}\n\n")
 		}
 
 		for _, t := range sec.Ints {
 			intsTypes[t.Name] = true
-			xprintMultiline("", t.Docs, true)
-			name := typescriptName(t.Name, typescriptNames)
+			if findTypeMapping(opts.TypeMappings, t.Name) != nil {
+				continue
+			}
+			xprintTypeDocs(t.Docs, t.Name)
 			if len(t.Values) == 0 {
-				xprintf("export type %s = number\n\n", name)
+				xprintf("export type %s = number\n\n", t.Name)
 				continue
 			}
-			xprintf("export enum %s {\n", name)
-			names := map[string]string{}
-			for _, v := range t.Values {
-				lines := xprintMultiline("\t", v.Docs, false)
-				xprintf("\t%s = %d,", typescriptName(v.Name, names), v.Value)
-				xprintSingleline(lines)
-				xprintf("\n")
+			values := make([]enumValue, len(t.Values))
+			for i, v := range t.Values {
+				values[i] = enumValue{v.Name, fmt.Sprintf("%d", v.Value), v.Docs}
 			}
-			xprintf("}\n\n")
+			generateEnum(t.Name, values)
 		}
 
 		for _, t := range sec.Strings {
 			stringsTypes[t.Name] = true
-			xprintMultiline("", t.Docs, true)
-			name := typescriptName(t.Name, typescriptNames)
+			if findTypeMapping(opts.TypeMappings, t.Name) != nil {
+				continue
+			}
+			xprintTypeDocs(t.Docs, t.Name)
 			if len(t.Values) == 0 {
-				xprintf("export type %s = string\n\n", name)
+				xprintf("export type %s = string\n\n", t.Name)
 				continue
 			}
-			xprintf("export enum %s {\n", name)
-			names := map[string]string{}
-			for _, v := range t.Values {
-				lines := xprintMultiline("\t", v.Docs, false)
-				s := mustMarshalJSON(v.Value)
-				xprintf("\t%s = %s,", typescriptName(v.Name, names), s)
-				xprintSingleline(lines)
-				xprintf("\n")
+			values := make([]enumValue, len(t.Values))
+			for i, v := range t.Values {
+				values[i] = enumValue{v.Name, mustMarshalJSON(v.Value), v.Docs}
 			}
-			xprintf("}\n\n")
+			generateEnum(t.Name, values)
 		}
 
 		for _, subsec := range sec.Sections {
@@ -409,14 +895,17 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 
 	var generateFunctionTypes func(sec *sherpadoc.Section)
 	generateFunctionTypes = func(sec *sherpadoc.Section) {
-		for _, typ := range sec.Structs {
-			xprintf("	%s: %s,\n", mustMarshalJSON(typ.Name), mustMarshalJSON(typ))
+		typ := func(name string, t interface{}) {
+			xprintf("	%s: %s,\n", mustMarshalJSON(name), mustMarshalJSON(t))
 		}
-		for _, typ := range sec.Ints {
-			xprintf("	%s: %s,\n", mustMarshalJSON(typ.Name), mustMarshalJSON(typ))
+		for _, t := range sec.Structs {
+			typ(t.Name, t)
 		}
-		for _, typ := range sec.Strings {
-			xprintf("	%s: %s,\n", mustMarshalJSON(typ.Name), mustMarshalJSON(typ))
+		for _, t := range sec.Ints {
+			typ(t.Name, t)
+		}
+		for _, t := range sec.Strings {
+			typ(t.Name, t)
 		}
 
 		for _, subsec := range sec.Sections {
@@ -424,20 +913,110 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 		}
 	}
 
-	var generateParser func(sec *sherpadoc.Section)
-	generateParser = func(sec *sherpadoc.Section) {
+	var generateSafeParser func(sec *sherpadoc.Section)
+	generateSafeParser = func(sec *sherpadoc.Section) {
 		for _, typ := range sec.Structs {
-			xprintf("	%s: (v: any) => parse(%s, v) as %s,\n", typ.Name, mustMarshalJSON(typ.Name), typ.Name)
+			xprintf("	%s: (v: %s) => _sherpaSafeParse<%s>(%s, v, %s),\n", typ.Name, anyType(opts), identType{typ.Name}.TypescriptType(outContext), mustMarshalJSON(typ.Name), typeTable([][]string{{typ.Name}}))
 		}
 		for _, typ := range sec.Ints {
-			xprintf("	%s: (v: any) => parse(%s, v) as %s,\n", typ.Name, mustMarshalJSON(typ.Name), typ.Name)
+			xprintf("	%s: (v: %s) => _sherpaSafeParse<%s>(%s, v, %s),\n", typ.Name, anyType(opts), identType{typ.Name}.TypescriptType(outContext), mustMarshalJSON(typ.Name), typeTable([][]string{{typ.Name}}))
 		}
 		for _, typ := range sec.Strings {
-			xprintf("	%s: (v: any) => parse(%s, v) as %s,\n", typ.Name, mustMarshalJSON(typ.Name), typ.Name)
+			xprintf("	%s: (v: %s) => _sherpaSafeParse<%s>(%s, v, %s),\n", typ.Name, anyType(opts), identType{typ.Name}.TypescriptType(outContext), mustMarshalJSON(typ.Name), typeTable([][]string{{typ.Name}}))
 		}
 
 		for _, subsec := range sec.Sections {
-			generateParser(subsec)
+			generateSafeParser(subsec)
+		}
+	}
+
+	var generateCompiledStructs func(sec *sherpadoc.Section)
+	generateCompiledStructs = func(sec *sherpadoc.Section) {
+		for _, t := range sec.Structs {
+			xprintf("\t%s: _sherpaCompileStruct({\n", mustMarshalJSON(t.Name))
+			for _, f := range t.Fields {
+				xprintf("\t\t%s: %s,\n", propertyName(f.Name), compiledCheck(f.Typewords))
+			}
+			xprintf("\t}),\n")
+		}
+		for _, subsec := range sec.Sections {
+			generateCompiledStructs(subsec)
+		}
+	}
+
+	var generateCompiledSignatures func(sec *sherpadoc.Section)
+	generateCompiledSignatures = func(sec *sherpadoc.Section) {
+		checks := func(args []sherpadoc.Arg) string {
+			l := make([]string, len(args))
+			for i, a := range args {
+				l[i] = compiledCheck(a.Typewords)
+			}
+			return strings.Join(l, ", ")
+		}
+		for _, fn := range sec.Functions {
+			xprintf("\t%s: {params: [%s], returns: [%s]},\n", mustMarshalJSON(fn.Name), checks(fn.Params), checks(fn.Returns))
+		}
+		for _, subsec := range sec.Sections {
+			generateCompiledSignatures(subsec)
+		}
+	}
+
+	var generateTypeConsts func(sec *sherpadoc.Section)
+	generateTypeConsts = func(sec *sherpadoc.Section) {
+		for _, t := range sec.Structs {
+			xprintf("const %s: Struct = %s\n", typeConst(t.Name), mustMarshalJSON(t))
+		}
+		for _, t := range sec.Ints {
+			xprintf("const %s: Ints = %s\n", typeConst(t.Name), mustMarshalJSON(t))
+		}
+		for _, t := range sec.Strings {
+			xprintf("const %s: Strings = %s\n", typeConst(t.Name), mustMarshalJSON(t))
+		}
+		for _, subsec := range sec.Sections {
+			generateTypeConsts(subsec)
+		}
+	}
+
+	var generateGuards func(sec *sherpadoc.Section)
+	generateGuards = func(sec *sherpadoc.Section) {
+		guard := func(name string) {
+			xprintf("export const %s = (v: unknown): v is %s => _sherpaGuard(%s, v, %s)\n", names.companion("is"+name, name), identType{name}.TypescriptType(tc), mustMarshalJSON(name), typeTable([][]string{{name}}))
+		}
+		for _, typ := range sec.Structs {
+			guard(typ.Name)
+		}
+		for _, typ := range sec.Ints {
+			guard(typ.Name)
+		}
+		for _, typ := range sec.Strings {
+			guard(typ.Name)
+		}
+		for _, subsec := range sec.Sections {
+			generateGuards(subsec)
+		}
+	}
+
+	var generateParser func(sec *sherpadoc.Section)
+	generateParser = func(sec *sherpadoc.Section) {
+		// Parse uses the table with all types by default.
+		lazyTypes := func(name string) string {
+			if runtimeTypesMode(opts) != "lazy" {
+				return ""
+			}
+			return ", " + typeTable([][]string{{name}})
+		}
+		for _, typ := range sec.Structs {
+			xprintf("	%s: (v: %s) => parse(%s, v%s) as %s,\n", typ.Name, anyType(opts), mustMarshalJSON(typ.Name), lazyTypes(typ.Name), identType{typ.Name}.TypescriptType(outContext))
+		}
+		for _, typ := range sec.Ints {
+			xprintf("	%s: (v: %s) => parse(%s, v%s) as %s,\n", typ.Name, anyType(opts), mustMarshalJSON(typ.Name), lazyTypes(typ.Name), identType{typ.Name}.TypescriptType(outContext))
+		}
+		for _, typ := range sec.Strings {
+			xprintf("	%s: (v: %s) => parse(%s, v%s) as %s,\n", typ.Name, anyType(opts), mustMarshalJSON(typ.Name), lazyTypes(typ.Name), identType{typ.Name}.TypescriptType(outContext))
+		}
+
+		for _, subsec := range sec.Sections {
+			generateParser(subsec)
 		}
 	}
 
@@ -451,79 +1030,261 @@ func Generate(in io.Reader, out io.Writer, apiNameBaseURL string, opts Options)
 		}
 	}
 
-	var generateFunctions func(sec *sherpadoc.Section)
-	generateFunctions = func(sec *sherpadoc.Section) {
+	// xprintSubsectionDocs writes the name and documentation of subsection sec as
+	// comment before its functions, with JSDoc. The documentation of the top-level
+	// section is the JSDoc for the Client.
+	xprintSubsectionDocs := func(indent string, sec *sherpadoc.Section) {
+		xprintf("%s// %s\n", indent, sec.Name)
+		for _, line := range wrapDocLines(docLines(sec.Docs), opts.DocWidth) {
+			xprintf("%s//\n", indent)
+			xprintf("%s// %s\n", indent, line)
+		}
+		xprintf("\n")
+	}
+
+	// xprintFunctionDocs writes the documentation for function fn, as JSDoc with
+	// tags for the parameters and return values.
+	xprintFunctionDocs := func(indent string, fn *sherpadoc.Function, standalone bool) {
+		if !opts.JSDoc {
+			xprintMultiline(indent, fn.Docs, true)
+			return
+		}
+		var tags []string
+		if standalone {
+			tags = append(tags, "@param clientConfig Client, or authentication state and options for the call.")
+		}
+		if opts.NamedParams && len(fn.Params) > 0 {
+			tags = append(tags, "@param args Parameters by name.")
+		}
+		// Parameters and return values have no documentation in the sherpadoc, we
+		// describe them by type. Descriptions don't start with a type, it would be parsed
+		// as JSDoc type.
+		for i, p := range fn.Params {
+			name := names.params[fn][i]
+			if opts.NamedParams {
+				name = "args." + p.Name
+			}
+			t := typescriptType("parameter for "+fn.Name, p.Typewords, directionContext(tc, "in"))
+			tags = append(tags, linkTypes(fmt.Sprintf("@param %s Value of type %s.", name, t), namedTypes, ""))
+		}
+		if len(fn.Returns) == 1 {
+			t := typescriptType("return type for "+fn.Name, fn.Returns[0].Typewords, directionContext(tc, "out"))
+			tags = append(tags, linkTypes(fmt.Sprintf("@returns Value of type %s.", t), namedTypes, ""))
+		} else if len(fn.Returns) > 1 {
+			// Unnamed return values are named r0, r1, etc. by sherpadoc, we only mention
+			// other names, or all names for objects.
+			object := resultsMode(opts) == "object"
+			var results []string
+			for i, r := range fn.Returns {
+				t := typescriptType("return type for "+fn.Name, r.Typewords, directionContext(tc, "out"))
+				if !object && (r.Name == "" || r.Name == fmt.Sprintf("r%d", i)) {
+					results = append(results, t)
+				} else {
+					results = append(results, fmt.Sprintf("%s (%s)", r.Name, t))
+				}
+			}
+			what := "Tuple"
+			if object {
+				what = "Object"
+			}
+			tags = append(tags, linkTypes(fmt.Sprintf("@returns %s with %s.", what, strings.Join(results, ", ")), namedTypes, ""))
+		}
+		xprintJSDoc(indent, fn.Docs, "", tags)
+	}
+
+	var generateFunctions func(sec *sherpadoc.Section, standalone bool)
+	generateFunctions = func(sec *sherpadoc.Section, standalone bool) {
 		for i, fn := range sec.Functions {
 			whatParam := "pararameter for " + fn.Name
 			paramNameTypes := []string{}
 			paramNames := []string{}
 			sherpaParamTypes := [][]string{}
-			names := map[string]string{}
-			for _, p := range fn.Params {
-				name := typescriptName(p.Name, names)
-				v := fmt.Sprintf("%s: %s", name, typescriptType(whatParam, p.Typewords))
+			for j, p := range fn.Params {
+				name := names.params[fn][j]
+				v := fmt.Sprintf("%s: %s", name, typescriptType(whatParam, p.Typewords, directionContext(tc, "in")))
 				paramNameTypes = append(paramNameTypes, v)
 				paramNames = append(paramNames, name)
 				sherpaParamTypes = append(sherpaParamTypes, p.Typewords)
 			}
+			// With named parameters, a single object has the parameters as properties.
+			if opts.NamedParams && len(fn.Params) > 0 {
+				var props []string
+				paramNames = nil
+				for _, p := range fn.Params {
+					optional := ""
+					if isOptional(p.Typewords, opts) {
+						optional = "?"
+					}
+					props = append(props, fmt.Sprintf("%s%s: %s", propertyName(p.Name), optional, typescriptType(whatParam, p.Typewords, directionContext(tc, "in"))))
+					paramNames = append(paramNames, propertyAccess("args", p.Name))
+				}
+				paramNameTypes = []string{fmt.Sprintf("args: {%s}", strings.Join(props, ", "))}
+			}
 
-			var returnType string
+			var returnType, convert string
 			switch len(fn.Returns) {
 			case 0:
 				returnType = "void"
 			case 1:
 				what := "return type for " + fn.Name
-				returnType = typescriptType(what, fn.Returns[0].Typewords)
+				returnType = typescriptType(what, fn.Returns[0].Typewords, directionContext(tc, "out"))
 			default:
-				var types []string
+				var types, labeled, props, values []string
 				what := "return type for " + fn.Name
-				for _, t := range fn.Returns {
-					types = append(types, typescriptType(what, t.Typewords))
+				readonly := ""
+				if opts.Readonly {
+					readonly = "readonly "
+				}
+				for j, t := range fn.Returns {
+					tt := typescriptType(what, t.Typewords, directionContext(tc, "out"))
+					types = append(types, tt)
+					// Labels must be identifiers, we only label if all elements can be.
+					if t.Name != "" && propertyName(t.Name) == t.Name {
+						labeled = append(labeled, t.Name+": "+tt)
+					}
+					props = append(props, readonly+propertyName(t.Name)+": "+tt)
+					values = append(values, fmt.Sprintf("%s: r[%d]", propertyName(t.Name), j))
+				}
+				returnType = fmt.Sprintf("%s[%s]", readonly, strings.Join(types, ", "))
+				switch resultsMode(opts) {
+				case "labeled-tuple":
+					if len(labeled) == len(types) {
+						returnType = fmt.Sprintf("%s[%s]", readonly, strings.Join(labeled, ", "))
+					}
+				case "object":
+					// The tuple from the server is turned into an object.
+					convert = fmt.Sprintf(".then((r: %s) => ({%s}))", returnType, strings.Join(values, ", "))
+					returnType = fmt.Sprintf("{%s}", strings.Join(props, ", "))
 				}
-				returnType = fmt.Sprintf("[%s]", strings.Join(types, ", "))
 			}
 			sherpaReturnTypes := [][]string{}
 			for _, a := range fn.Returns {
 				sherpaReturnTypes = append(sherpaReturnTypes, a.Typewords)
 			}
 
-			name := typescriptName(fn.Name, typescriptNames)
-			xprintMultiline("\t", fn.Docs, true)
-			xprintf("\tasync %s(%s): Promise<%s> {\n", name, strings.Join(paramNameTypes, ", "), returnType)
-			xprintf("\t\tconst fn: string = %s\n", mustMarshalJSON(fn.Name))
-			xprintf("\t\tconst paramTypes: string[][] = %s\n", mustMarshalJSON(sherpaParamTypes))
-			xprintf("\t\tconst returnTypes: string[][] = %s\n", mustMarshalJSON(sherpaReturnTypes))
-			xprintf("\t\tconst params: any[] = [%s]\n", strings.Join(paramNames, ", "))
-			xprintf("\t\treturn await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as %s\n", returnType)
-			xprintf("\t}\n")
-			if i < len(sec.Functions)-1 {
+			// Methods of Client, or standalone functions with a ClientConfig parameter.
+			indent := "\t"
+			call := "_sherpaCall(this.baseURL, this.authState, { ...this.options }, "
+			if standalone {
+				indent = ""
+				xprintFunctionDocs(indent, fn, standalone)
+				xprintf("export const %s = async (%s): Promise<%s> => {\n", names.functions[fn], strings.Join(append([]string{"clientConfig: ClientConfig"}, paramNameTypes...), ", "), returnType)
+				call = "_sherpaCallConfig(clientConfig, "
+			} else {
+				xprintFunctionDocs(indent, fn, standalone)
+				xprintf("\tasync %s(%s): Promise<%s> {\n", names.methods[fn], strings.Join(paramNameTypes, ", "), returnType)
+			}
+			xprintf("%s\tconst fn: string = %s\n", indent, mustMarshalJSON(fn.Name))
+			if msg, ok := deprecation(fn.Docs); ok && opts.WarnDeprecated {
+				xprintf("%s\t_sherpaWarnDeprecated(fn, %s)\n", indent, mustMarshalJSON(msg))
+			}
+			switch runtimeTypesMode(opts) {
+			case "none":
+				xprintf("%s\tconst params: %s[] = [%s]\n", indent, anyType(opts), strings.Join(paramNames, ", "))
+				xprintf("%s\treturn await %snull, null, null, fn, params)%s as %s\n", indent, call, convert, returnType)
+			case "lazy":
+				var tws [][]string
+				tws = append(tws, sherpaParamTypes...)
+				tws = append(tws, sherpaReturnTypes...)
+				xprintf("%s\tconst paramTypes: string[][] = %s\n", indent, mustMarshalJSON(sherpaParamTypes))
+				xprintf("%s\tconst returnTypes: string[][] = %s\n", indent, mustMarshalJSON(sherpaReturnTypes))
+				xprintf("%s\tconst types: TypenameMap = %s\n", indent, typeTable(tws))
+				xprintf("%s\tconst params: %s[] = [%s]\n", indent, anyType(opts), strings.Join(paramNames, ", "))
+				xprintf("%s\treturn await %sparamTypes, returnTypes, types, fn, params)%s as %s\n", indent, call, convert, returnType)
+			default:
+				xprintf("%s\tconst paramTypes: string[][] = %s\n", indent, mustMarshalJSON(sherpaParamTypes))
+				xprintf("%s\tconst returnTypes: string[][] = %s\n", indent, mustMarshalJSON(sherpaReturnTypes))
+				xprintf("%s\tconst params: %s[] = [%s]\n", indent, anyType(opts), strings.Join(paramNames, ", "))
+				xprintf("%s\treturn await %sparamTypes, returnTypes, null, fn, params)%s as %s\n", indent, call, convert, returnType)
+			}
+			xprintf("%s}\n", indent)
+			if standalone || i < len(sec.Functions)-1 {
 				xprintf("\n")
 			}
 		}
 
 		for _, s := range sec.Sections {
-			generateFunctions(s)
+			if opts.JSDoc && standalone {
+				xprintSubsectionDocs("", s)
+			} else if opts.JSDoc {
+				if len(sec.Functions) > 0 {
+					xprintf("\n")
+				}
+				xprintSubsectionDocs("\t", s)
+			}
+			generateFunctions(s, standalone)
 		}
 	}
 
 	xprintf("// NOTE: GENERATED by github.com/mjl-/sherpats, DO NOT MODIFY\n\n")
+	xprintf("%s", renameReport(names.renames))
+	imports := map[string]bool{}
+	for _, m := range opts.TypeMappings {
+		if m.Import != "" && !imports[m.Import] {
+			xprintf("%s\n", m.Import)
+			imports[m.Import] = true
+		}
+	}
+	if len(imports) > 0 {
+		xprintf("\n")
+	}
 	if opts.Namespace != "" {
 		xprintf("namespace %s {\n\n", opts.Namespace)
 	}
 	generateTypes(&doc)
-	xprintf("export const structTypes: {[typename: string]: boolean} = %s\n", mustMarshalJSON(structTypes))
-	xprintf("export const stringsTypes: {[typename: string]: boolean} = %s\n", mustMarshalJSON(stringsTypes))
-	xprintf("export const intsTypes: {[typename: string]: boolean} = %s\n", mustMarshalJSON(intsTypes))
-	xprintf("export const types: TypenameMap = {\n")
-	generateFunctionTypes(&typesdoc)
-	xprintf("}\n\n")
-	xprintf("export const parser = {\n")
-	generateParser(&doc)
-	xprintf("}\n\n")
-	generateSectionDocs(&doc)
-	xprintf(`let defaultOptions: ClientOptions = {slicesNullable: %v, mapsNullable: %v, nullableOptional: %v}
-
-export class Client {
+	if runtimeTypesMode(opts) != "none" {
+		xprintf("export const structTypes: {[typename: string]: boolean} = %s\n", mustMarshalJSON(structTypes))
+		xprintf("export const stringsTypes: {[typename: string]: boolean} = %s\n", mustMarshalJSON(stringsTypes))
+		xprintf("export const intsTypes: {[typename: string]: boolean} = %s\n", mustMarshalJSON(intsTypes))
+		if hasTypeHooks(opts) {
+			xprintf("%s", typeHooksTS)
+			xprintf("export const typeHooks: {[typename: string]: TypeHooks} = {\n")
+			for _, m := range opts.TypeMappings {
+				if m.Parse == "" && m.Serialize == "" {
+					continue
+				}
+				var hooks []string
+				if m.Parse != "" {
+					hooks = append(hooks, "parse: "+m.Parse)
+				}
+				if m.Serialize != "" {
+					hooks = append(hooks, "serialize: "+m.Serialize)
+				}
+				xprintf("\t%s: {%s},\n", mustMarshalJSON(m.Name), strings.Join(hooks, ", "))
+			}
+			xprintf("}\n")
+		}
+		if runtimeTypesMode(opts) == "lazy" {
+			generateTypeConsts(&typesdoc)
+			xprintf("\n")
+		}
+		if runtimeTypesMode(opts) == "full" {
+			xprintf("export const types: TypenameMap = {\n")
+			generateFunctionTypes(&typesdoc)
+			xprintf("}\n\n")
+		}
+		xprintf("export const parser = {\n")
+		generateParser(&doc)
+		xprintf("}\n\n")
+		if opts.SafeParser {
+			xprintComment("Like parser, but returning all issues instead of throwing an exception for the first.")
+			xprintf("export const safeParser = {\n")
+			generateSafeParser(&doc)
+			xprintf("}\n\n")
+		}
+		if opts.Guards {
+			generateGuards(&doc)
+			xprintf("\n")
+		}
+	}
+	if !opts.JSDoc {
+		generateSectionDocs(&doc)
+	}
+	xprintf("let defaultOptions: ClientOptions = {slicesNullable: %v, mapsNullable: %v, nullableOptional: %v}\n\n", opts.SlicesNullable, opts.MapsNullable, opts.NullableOptional)
+	if opts.JSDoc {
+		xprintJSDoc("", doc.Docs, "", nil)
+	}
+	xprintf(`export class Client {
 	private baseURL: string
 	public authState: AuthState
 	public options: ClientOptions
@@ -545,12 +1306,16 @@ export class Client {
 		const c = new Client()
 		c.authState = this.authState
 		c.options = { ...this.options, ...options }
+		c.baseURL = c.options.baseURL || defaultBaseURL
 		return c
 	}
 
-`, opts.SlicesNullable, opts.MapsNullable, opts.NullableOptional)
-	generateFunctions(&doc)
+`)
+	generateFunctions(&doc, false)
 	xprintf("}\n\n")
+	if opts.StandaloneFunctions {
+		generateFunctions(&doc, true)
+	}
 
 	const findBaseURL = `(function() {
 	let p = location.pathname
@@ -569,20 +1334,289 @@ export class Client {
 		apiJS = strings.Replace(findBaseURL, "API_NAME", apiNameBaseURL, -1)
 	}
 	xprintf("%s\n", strings.Replace(libTS, "BASEURL", apiJS, -1))
+	// Values of named types are only converted with hooks with type mappings.
+	var verifyHooks, cloneHooks, equalHooks string
+	if hasTypeHooks(opts) {
+		verifyHooks, cloneHooks, equalHooks = verifyHooksTS, cloneHooksTS, equalHooksTS
+	}
+	// Verification only uses compiled checks with opts.CompiledChecks.
+	compiledStruct, compiledSignature := "", ""
+	if opts.CompiledChecks {
+		compiledStruct, compiledSignature = compiledStructTS, compiledSignatureTS
+	}
+	// Only full runtime types have the table with all types.
+	allTypes := "{}"
+	if runtimeTypesMode(opts) == "full" {
+		allTypes = "types"
+	}
+	typeOptions := fmt.Sprintf("{bigint: %v, timestamp: %s, splitTypes: %v}", opts.BigInt, mustMarshalJSON(timestampMode(opts)), opts.SplitTypes)
+	hooks := strings.NewReplacer("\t\tVERIFYHOOKS\n", verifyHooks, "\tCLONEHOOKS\n", cloneHooks, "\tEQUALHOOKS\n", equalHooks, "\tCOMPILEDSTRUCT\n", compiledStruct, "\tCOMPILEDSIGNATURE\n", compiledSignature, "ALLTYPES", allTypes, "TYPEOPTIONS", typeOptions)
+	if runtimeTypesMode(opts) == "none" {
+		xprintf("%s\n", noVerifierTS)
+	} else {
+		xprintf("%s\n", hooks.Replace(verifierTS))
+		if opts.CloneEqual {
+			xprintf("%s\n", hooks.Replace(cloneEqualTS))
+		}
+		if opts.SafeParser {
+			xprintf("%s\n", safeParserTS)
+		}
+		if opts.Guards {
+			xprintf("%s\n", guardTS)
+		}
+	}
+	// JSON with large integers is only parsed and written as BigInt with opts.BigInt.
+	parseJSON, stringifyJSON := "JSON.parse", "JSON.stringify"
+	if opts.BigInt {
+		parseJSON = "_sherpaParseJSONBigInt"
+		stringifyJSON = "_sherpaStringifyJSONBigInt"
+	}
+	xprintf("%s\n", strings.NewReplacer("PARSEJSON", parseJSON, "STRINGIFYJSON", stringifyJSON, "ALLTYPES", allTypes).Replace(clientTS))
+	if opts.BigInt {
+		xprintf("%s\n", bigintTS)
+	}
+	if opts.WarnDeprecated {
+		xprintf("%s\n", deprecatedTS)
+	}
+	if opts.CompiledChecks {
+		xprintf("%s\n", compiledChecksTS)
+		xprintf("// Checks compiled from the typewords of structs and functions, used instead of\n")
+		xprintf("// interpreting typewords during verification.\n")
+		xprintf("const _sherpaCompiledStructs: {[typename: string]: _sherpaCompiledStruct} = {\n")
+		generateCompiledStructs(&doc)
+		xprintf("}\n")
+		xprintf("const _sherpaCompiledSignatures: {[fn: string]: _sherpaCompiledSignature} = {\n")
+		generateCompiledSignatures(&doc)
+		xprintf("}\n")
+	}
 	if opts.Namespace != "" {
 		xprintf("}\n")
 	}
 
-	err = bout.Flush()
+	err := bout.Flush()
 	if err != nil {
 		panic(genError{err})
 	}
 	return nil
 }
 
-func typescriptType(what string, typeTokens []string) string {
+// parseSherpadoc reads and checks the sherpadoc from in, with the transformations
+// from opts applied. Errors are raised as genError panics.
+func parseSherpadoc(in io.Reader, opts Options) sherpadoc.Section {
+	var doc sherpadoc.Section
+	err := json.NewDecoder(in).Decode(&doc)
+	if err != nil {
+		panic(genError{fmt.Errorf("parsing sherpadoc json: %s", err)})
+	}
+
+	const sherpadocVersion = 1
+	if doc.SherpadocVersion != sherpadocVersion {
+		panic(genError{fmt.Errorf("unexpected sherpadoc version %d, expected %d", doc.SherpadocVersion, sherpadocVersion)})
+	}
+
+	if opts.BytesToString {
+		toString := func(tw []string) []string {
+			n := len(tw) - 1
+			for i := 0; i < n; i++ {
+				if tw[i] == "[]" && tw[i+1] == "uint8" {
+					if opts.SlicesNullable && (i == 0 || tw[i-1] != "nullable") {
+						tw[i] = "nullable"
+						tw[i+1] = "string"
+						i++
+					} else {
+						tw[i] = "string"
+						copy(tw[i+1:], tw[i+2:])
+						tw = tw[:len(tw)-1]
+						n--
+					}
+				}
+			}
+			return tw
+		}
+
+		var bytesToString func(sec *sherpadoc.Section)
+		bytesToString = func(sec *sherpadoc.Section) {
+			for i := range sec.Functions {
+				for j := range sec.Functions[i].Params {
+					sec.Functions[i].Params[j].Typewords = toString(sec.Functions[i].Params[j].Typewords)
+				}
+				for j := range sec.Functions[i].Returns {
+					sec.Functions[i].Returns[j].Typewords = toString(sec.Functions[i].Returns[j].Typewords)
+				}
+			}
+			for i := range sec.Structs {
+				for j := range sec.Structs[i].Fields {
+					sec.Structs[i].Fields[j].Typewords = toString(sec.Structs[i].Fields[j].Typewords)
+				}
+			}
+			for _, s := range sec.Sections {
+				bytesToString(s)
+			}
+		}
+		bytesToString(&doc)
+	}
+
+	// Validate the sherpadoc.
+	err = sherpadoc.Check(&doc)
+	if err != nil {
+		panic(genError{err})
+	}
+	return doc
+}
+
+// referencedTypes returns the names of the named types needed for verifying
+// values of typewords, including types referenced by struct fields.
+func referencedTypes(namedTypes map[string]interface{}, tws [][]string) []string {
+	var l []string
+	seen := map[string]bool{}
+	var mark func(tw []string)
+	mark = func(tw []string) {
+		for _, w := range tw {
+			t, ok := namedTypes[w]
+			if !ok || seen[w] {
+				continue
+			}
+			seen[w] = true
+			l = append(l, w)
+			if st, ok := t.(sherpadoc.Struct); ok {
+				for _, f := range st.Fields {
+					mark(f.Typewords)
+				}
+			}
+		}
+	}
+	for _, tw := range tws {
+		mark(tw)
+	}
+	return l
+}
+
+// compiledCheck returns a TypeScript expression for a _sherpaCheck for typewords.
+func compiledCheck(tw []string) string {
+	switch tw[0] {
+	case "nullable":
+		return "_sherpaCheckNullable(" + compiledCheck(tw[1:]) + ")"
+	case "[]":
+		return "_sherpaCheckArray(" + compiledCheck(tw[1:]) + ")"
+	case "{}":
+		return "_sherpaCheckMap(" + compiledCheck(tw[1:]) + ")"
+	}
+	return "_sherpaCheckBase(" + mustMarshalJSON(tw[0]) + ")"
+}
+
+// isOptional returns whether a struct field or named parameter of type tw is
+// generated as optional.
+func isOptional(tw []string, opts Options) bool {
+	return opts.NullableOptional && (tw[0] == "nullable" || opts.SlicesNullable && tw[0] == "[]" || opts.MapsNullable && tw[0] == "{}")
+}
+
+// propertyAccess returns an expression accessing property name of variable v.
+func propertyAccess(v, name string) string {
+	if propertyName(name) == name {
+		return v + "." + name
+	}
+	return v + "[" + mustMarshalJSON(name) + "]"
+}
+
+// hasNamedType returns whether a struct, ints or strings type exists.
+func hasNamedType(sec *sherpadoc.Section, name string) bool {
+	for _, t := range sec.Structs {
+		if t.Name == name {
+			return true
+		}
+	}
+	for _, t := range sec.Ints {
+		if t.Name == name {
+			return true
+		}
+	}
+	for _, t := range sec.Strings {
+		if t.Name == name {
+			return true
+		}
+	}
+	for _, subsec := range sec.Sections {
+		if hasNamedType(subsec, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeTypesMode returns the runtime type information generated, with the
+// default applied.
+func runtimeTypesMode(opts Options) string {
+	if opts.RuntimeTypes == "" {
+		return "full"
+	}
+	return opts.RuntimeTypes
+}
+
+// resultsMode returns the representation of multiple return values, with the
+// default applied.
+func resultsMode(opts Options) string {
+	if opts.Results == "" {
+		return "tuple"
+	}
+	return opts.Results
+}
+
+// enumsMode returns the representation of ints and strings types with values,
+// with the default applied.
+func enumsMode(opts Options) string {
+	if opts.Enums == "" {
+		return "enum"
+	}
+	return opts.Enums
+}
+
+// enumValueRefs returns a comma-separated list of references to values, of
+// the form required by the enums mode.
+func enumValueRefs(name string, values []enumValue, opts Options) string {
+	refs := make([]string, len(values))
+	for i, v := range values {
+		if enumsMode(opts) == "union" {
+			refs[i] = v.Literal
+		} else if propertyName(v.Name) == v.Name {
+			refs[i] = name + "." + v.Name
+		} else {
+			refs[i] = name + "[" + mustMarshalJSON(v.Name) + "]"
+		}
+	}
+	return strings.Join(refs, ", ")
+}
+
+// enumValueKey returns the value as property name in an object literal.
+func enumValueKey(v enumValue) string {
+	if strings.HasPrefix(v.Literal, `"`) {
+		return v.Literal
+	}
+	return mustMarshalJSON(v.Literal)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// enumValue is a value of an ints or strings type, with Literal in TypeScript syntax.
+type enumValue struct {
+	Name, Literal, Docs string
+}
+
+// timestampMode returns the timestamp representation, with the default applied.
+func timestampMode(opts Options) string {
+	if opts.Timestamp == "" {
+		return "date"
+	}
+	return opts.Timestamp
+}
+
+func typescriptType(what string, typeTokens []string, tc typeContext) string {
 	t := parseType(what, typeTokens)
-	return t.TypescriptType()
+	return t.TypescriptType(tc)
 }
 
 func parseType(what string, tokens []string) sherpaType {
@@ -614,6 +1648,140 @@ func parseType(what string, tokens []string) sherpaType {
 	}
 }
 
+// propertyName returns name for use as property name in an interface or enum.
+// Keywords are allowed as property names, so name is returned as is if it is a
+// valid identifier. Otherwise it is quoted. The JSON field names of structs are
+// used as property names as is, so the generated types match the data.
+func propertyName(name string) string {
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || i > 0 && c >= '0' && c <= '9') {
+			return mustMarshalJSON(name)
+		}
+	}
+	if name == "" {
+		return `""`
+	}
+	return name
+}
+
+// splitDocTags returns the lines of documentation without paragraphs starting
+// with "Deprecated:" or "Experimental:", and those paragraphs as JSDoc tags.
+func splitDocTags(lines []string) (body, tags []string) {
+	var tag bool
+	for i, line := range lines {
+		if line == "" {
+			tag = false
+		} else if i == 0 || lines[i-1] == "" {
+			if strings.HasPrefix(line, "Deprecated:") {
+				tag = true
+				line = strings.TrimSpace("@deprecated " + strings.TrimSpace(strings.TrimPrefix(line, "Deprecated:")))
+			} else if strings.HasPrefix(line, "Experimental:") {
+				tag = true
+				line = strings.TrimSpace("@experimental " + strings.TrimSpace(strings.TrimPrefix(line, "Experimental:")))
+			}
+		}
+		if tag {
+			tags = append(tags, line)
+		} else if line != "" || len(body) > 0 && body[len(body)-1] != "" {
+			body = append(body, line)
+		}
+	}
+	// Remove empty lines left at the end by removed paragraphs.
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+	}
+	return
+}
+
+// deprecation returns the text of a "Deprecated:" paragraph in docs, and
+// whether docs have such a paragraph.
+func deprecation(docs string) (string, bool) {
+	_, tags := splitDocTags(docLines(docs))
+	var msg []string
+	for _, t := range tags {
+		if strings.HasPrefix(t, "@experimental") {
+			if msg != nil {
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(t, "@deprecated") {
+			if msg != nil {
+				break
+			}
+			t = strings.TrimSpace(strings.TrimPrefix(t, "@deprecated"))
+			msg = []string{}
+		}
+		if msg != nil && t != "" {
+			msg = append(msg, t)
+		}
+	}
+	return strings.Join(msg, " "), msg != nil
+}
+
+// wrapDocLines returns lines with lines longer than width split at spaces.
+// Indented lines and lines without spaces are kept as is.
+func wrapDocLines(lines []string, width int) []string {
+	if width <= 0 {
+		return lines
+	}
+	var r []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			r = append(r, line)
+			continue
+		}
+		var cur string
+		for _, w := range strings.Fields(line) {
+			if cur != "" && len(cur)+1+len(w) > width {
+				r = append(r, cur)
+				cur = ""
+			}
+			if cur != "" {
+				cur += " "
+			}
+			cur += w
+		}
+		r = append(r, cur)
+	}
+	return r
+}
+
+// linkTypes returns line with names of types, either as word or as Go doc link
+// like "[Name]", replaced with JSDoc links. Name self is not linked. Indented
+// lines, e.g. code examples, are kept as is.
+func linkTypes(line string, types map[string]interface{}, self string) string {
+	if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+		return line
+	}
+	isWord := func(c byte) bool {
+		return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$'
+	}
+	var r string
+	for i := 0; i < len(line); {
+		if !isWord(line[i]) || i > 0 && isWord(line[i-1]) {
+			r += line[i : i+1]
+			i++
+			continue
+		}
+		e := i
+		for e < len(line) && isWord(line[e]) {
+			e++
+		}
+		w := line[i:e]
+		if _, ok := types[w]; !ok || w == self {
+			r += w
+		} else if strings.HasSuffix(r, "[") && e < len(line) && line[e] == ']' {
+			r = r[:len(r)-1] + "{@link " + w + "}"
+			e++
+		} else {
+			r += "{@link " + w + "}"
+		}
+		i = e
+	}
+	return r
+}
+
 func docLines(s string) []string {
 	s = strings.TrimSpace(s)
 	if s == "" {
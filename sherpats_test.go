@@ -0,0 +1,561 @@
+package sherpats
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "write generated code to testdata/default.ts")
+
+// TestGenerate compares code generated for testdata/api.json with default
+// options against testdata/default.ts. After intended changes, run "go test
+// -update" and review the differences. Other options are tested with fragments
+// of the generated code.
+func TestGenerate(t *testing.T) {
+	buf := generateTestAPI(t, Options{})
+	const p = "testdata/default.ts"
+	if *update {
+		if err := os.WriteFile(p, buf, 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	exp, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(buf, exp) {
+		t.Fatalf("generated code differs from %s, see go test -update", p)
+	}
+}
+
+// generateTestAPI returns the code generated for testdata/api.json.
+func generateTestAPI(t *testing.T, opts Options) []byte {
+	t.Helper()
+	f, err := os.Open("testdata/api.json")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var b bytes.Buffer
+	if err := Generate(f, &b, "api", opts); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	return b.Bytes()
+}
+
+// checkFragments generates code for testdata/api.json with opts, and checks it
+// contains the fragments in contains, and not those in absent.
+func checkFragments(t *testing.T, opts Options, contains, absent []string) {
+	t.Helper()
+	buf := generateTestAPI(t, opts)
+	for _, s := range contains {
+		if !bytes.Contains(buf, []byte(s)) {
+			t.Errorf("generated code does not contain %q", s)
+		}
+	}
+	for _, s := range absent {
+		if bytes.Contains(buf, []byte(s)) {
+			t.Errorf("generated code contains %q", s)
+		}
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	checkFragments(t, Options{BigInt: true},
+		[]string{
+			"\tID: bigint  // ID of user.\n",
+			"async Login(name: string, fn0: bigint): Promise<[User, string]> {",
+			"async delete(ids: bigint[] | null, kind: Kind): Promise<void> {",
+			"const _sherpaTypeOptions: _sherpaTypeOptions = {bigint: true, timestamp: \"date\", splitTypes: false}\n",
+			"resp = _sherpaParseJSONBigInt(req.responseText)",
+			"req.send(_sherpaStringifyJSONBigInt({ params: params }))",
+			"const _sherpaParseJSONBigInt = ",
+		},
+		// Bigint determines the types, it cannot be changed with ClientOptions.
+		[]string{"bigint?: boolean", "options.bigint"},
+	)
+	checkFragments(t, Options{},
+		[]string{"\tID: number  // ID of user.\n", "async delete(ids: string[] | null, kind: Kind): Promise<void> {", "const _sherpaTypeOptions: _sherpaTypeOptions = {bigint: false, timestamp: \"date\", splitTypes: false}\n"},
+		[]string{"const _sherpaParseJSONBigInt", "const _sherpaStringifyJSONBigInt"},
+	)
+}
+
+func TestWrapDocLines(t *testing.T) {
+	tests := []struct {
+		lines []string
+		width int
+		r     []string
+	}{
+		{[]string{"a bb ccc dddd"}, 0, []string{"a bb ccc dddd"}},
+		{[]string{"a bb ccc dddd"}, 8, []string{"a bb ccc", "dddd"}},
+		{[]string{"a bb ccc dddd"}, 4, []string{"a bb", "ccc", "dddd"}},
+		{[]string{"averylongword x"}, 4, []string{"averylongword", "x"}},
+		{[]string{"a  bb", "", "ccc"}, 4, []string{"a bb", "", "ccc"}},
+		{[]string{"\tindented code is kept"}, 4, []string{"\tindented code is kept"}},
+		{[]string{" indented too"}, 4, []string{" indented too"}},
+	}
+	for _, test := range tests {
+		if r := wrapDocLines(test.lines, test.width); !reflect.DeepEqual(r, test.r) {
+			t.Errorf("wrapDocLines(%q, %d) = %q, expected %q", test.lines, test.width, r, test.r)
+		}
+	}
+}
+
+func TestLinkTypes(t *testing.T) {
+	types := map[string]interface{}{"User": nil, "Kind": nil}
+	tests := []struct {
+		line string
+		self string
+		r    string
+	}{
+		{"Get returns a User.", "", "Get returns a {@link User}."},
+		{"See [User] and [Kind].", "", "See {@link User} and {@link Kind}."},
+		{"A User has a Kind.", "User", "A User has a {@link Kind}."},
+		{"Users and SuperUser are not types.", "", "Users and SuperUser are not types."},
+		{"[Other] is not a type.", "", "[Other] is not a type."},
+		{"[User ] is not a doc link.", "", "[{@link User} ] is not a doc link."},
+		{"\tu := User{}", "", "\tu := User{}"},
+	}
+	for _, test := range tests {
+		if r := linkTypes(test.line, types, test.self); r != test.r {
+			t.Errorf("linkTypes(%q, %q) = %q, expected %q", test.line, test.self, r, test.r)
+		}
+	}
+}
+
+func TestSplitDocTags(t *testing.T) {
+	tests := []struct {
+		lines      []string
+		body, tags []string
+	}{
+		{nil, nil, nil},
+		{[]string{"Get returns a user."}, []string{"Get returns a user."}, nil},
+		{
+			[]string{"Get returns a user.", "", "Deprecated: Use GetUser.", "Removed soon.", "", "More docs."},
+			[]string{"Get returns a user.", "", "More docs."},
+			[]string{"@deprecated Use GetUser.", "Removed soon."},
+		},
+		{
+			[]string{"Get returns a user.", "", "Experimental:"},
+			[]string{"Get returns a user."},
+			[]string{"@experimental"},
+		},
+		{
+			[]string{"Deprecated: Use GetUser.", "", "Experimental: May change."},
+			nil,
+			[]string{"@deprecated Use GetUser.", "@experimental May change."},
+		},
+		{
+			// Only at the start of a paragraph.
+			[]string{"Get returns a user.", "Deprecated: not a tag."},
+			[]string{"Get returns a user.", "Deprecated: not a tag."},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		body, tags := splitDocTags(test.lines)
+		if !reflect.DeepEqual(body, test.body) || !reflect.DeepEqual(tags, test.tags) {
+			t.Errorf("splitDocTags(%q) = %q, %q, expected %q, %q", test.lines, body, tags, test.body, test.tags)
+		}
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	tests := []struct {
+		docs       string
+		msg        string
+		deprecated bool
+	}{
+		{"", "", false},
+		{"Get returns a user.", "", false},
+		{"Get returns a user.\n\nExperimental: May change.", "", false},
+		{"Get returns a user.\n\nDeprecated:", "", true},
+		{"Get returns a user.\n\nDeprecated: Use GetUser.\nRemoved soon.\n\nMore docs.", "Use GetUser. Removed soon.", true},
+		{"Experimental: May change.\n\nDeprecated: Use GetUser.\n\nExperimental: Again.", "Use GetUser.", true},
+	}
+	for _, test := range tests {
+		msg, deprecated := deprecation(test.docs)
+		if msg != test.msg || deprecated != test.deprecated {
+			t.Errorf("deprecation(%q) = %q, %v, expected %q, %v", test.docs, msg, deprecated, test.msg, test.deprecated)
+		}
+	}
+}
+
+func TestPropertyName(t *testing.T) {
+	tests := []struct {
+		name, r string
+	}{
+		{"Name", "Name"},
+		{"class", "class"},
+		{"_id$2", "_id$2"},
+		{"my-field", `"my-field"`},
+		{"2fa", `"2fa"`},
+		{"a b", `"a b"`},
+		{"", `""`},
+	}
+	for _, test := range tests {
+		if r := propertyName(test.name); r != test.r {
+			t.Errorf("propertyName(%q) = %s, expected %s", test.name, r, test.r)
+		}
+	}
+}
+
+func TestVerbatimNames(t *testing.T) {
+	// Field and enum value names are not renamed, keywords are valid property
+	// names. Other names are quoted.
+	doc := testDoc(
+		`{"Name": "Get", "Docs": "", "Params": [], "Returns": [{"Name": "r0", "Typewords": ["Thing"]}]}`,
+		`{"Name": "Thing", "Docs": "", "Fields": [{"Name": "class", "Docs": "", "Typewords": ["string"]}, {"Name": "my-field", "Docs": "", "Typewords": ["State"]}]}`,
+		`{"Name": "State", "Docs": "", "Values": [{"Name": "default", "Value": "d", "Docs": ""}, {"Name": "in-progress", "Value": "p", "Docs": ""}]}`,
+	)
+	var b bytes.Buffer
+	if err := Generate(strings.NewReader(doc), &b, "api", Options{}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	for _, s := range []string{"\tclass: string\n", "\t\"my-field\": State\n", "\tdefault = \"d\",\n", "\t\"in-progress\" = \"p\",\n"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("generated code does not contain %q", s)
+		}
+	}
+
+	findings, err := Lint(strings.NewReader(doc), Options{})
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+	for _, f := range findings {
+		if strings.Contains(f.Message, "renamed") {
+			t.Errorf("unexpected lint finding %s", f)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	checkFragments(t, Options{Timestamp: "string"},
+		[]string{"\tCreated: string\n", `const _sherpaTypeOptions: _sherpaTypeOptions = {bigint: false, timestamp: "string", splitTypes: false}`},
+		// The timestamp mode determines the types, it cannot be changed with ClientOptions.
+		[]string{"timestamp?:"},
+	)
+	checkFragments(t, Options{Timestamp: "temporal"},
+		[]string{"\tCreated: Temporal.Instant\n", `const _sherpaTypeOptions: _sherpaTypeOptions = {bigint: false, timestamp: "temporal", splitTypes: false}`},
+		nil,
+	)
+}
+
+func TestTypeMappings(t *testing.T) {
+	decimal := TypeMapping{Name: "Free", Import: `import {Decimal} from "decimal.js"`, Type: "Decimal", Parse: "(v: string) => new Decimal(v)", Serialize: "(v: Decimal) => v.toString()"}
+	checkFragments(t, Options{TypeMappings: []TypeMapping{decimal}},
+		[]string{
+			"import {Decimal} from \"decimal.js\"\n",
+			"\t\"Free\": {parse: (v: string) => new Decimal(v), serialize: (v: Decimal) => v.toString()},\n",
+			"\tFree: (v: any) => parse(\"Free\", v) as Decimal,\n",
+			"const hooks = typeHooks[w]",
+		},
+		[]string{"export type Free = "},
+	)
+
+	// Without parse and serialize, no hooks are generated.
+	checkFragments(t, Options{TypeMappings: []TypeMapping{{Name: "Free", Type: "string & {__free: true}"}}},
+		[]string{"\tFree: (v: any) => parse(\"Free\", v) as string & {__free: true},\n"},
+		[]string{"export type Free = ", "typeHooks"},
+	)
+}
+
+func TestEnums(t *testing.T) {
+	checkFragments(t, Options{Enums: "union"},
+		[]string{
+			"export type Kind =\n\t| \"admin\"  // Administrator.\n\t| \"regular\"\n",
+			"export const KindValues: readonly Kind[] = Object.freeze([\"admin\", \"regular\"])\n",
+			"export type Level =\n\t| 1  // Low level.\n\t| 2\n",
+		},
+		[]string{"export enum "},
+	)
+	checkFragments(t, Options{Enums: "const-object"},
+		[]string{
+			"export const Kind = {\n\tAdmin: \"admin\",  // Administrator.\n\tdefault: \"regular\",\n} as const\n",
+			"export type Kind = typeof Kind[keyof typeof Kind]\n",
+			"export type Level = typeof Level[keyof typeof Level]\n",
+		},
+		[]string{"export enum ", "KindValues"},
+	)
+}
+
+func TestEnumHelpers(t *testing.T) {
+	checkFragments(t, Options{EnumHelpers: true},
+		[]string{
+			"export const KindValues: readonly Kind[] = Object.freeze([Kind.Admin, Kind.default])\n",
+			"export const KindNames: Readonly<Record<Kind, string>> = Object.freeze({\n\t\"admin\": \"Admin\",\n\t\"regular\": \"default\",\n})\n",
+			"export const KindLabels: Readonly<Record<Kind, string>> = Object.freeze({\n\t\"admin\": \"Administrator.\",\n\t\"regular\": \"default\",\n})\n",
+			"export const levelFromString = (s: string): Level | undefined => LevelValues.find(v => '' + v === s)\n",
+		},
+		nil,
+	)
+
+	// Union enums already have the values, they are not generated twice.
+	buf := generateTestAPI(t, Options{Enums: "union", EnumHelpers: true})
+	if n := bytes.Count(buf, []byte("export const KindValues")); n != 1 {
+		t.Fatalf("got %d KindValues, expected 1", n)
+	}
+}
+
+func TestGuards(t *testing.T) {
+	checkFragments(t, Options{Guards: true},
+		[]string{
+			"export const isUser = (v: unknown): v is User => _sherpaGuard(\"User\", v, types)\n",
+			"export const isLevel = (v: unknown): v is Level => _sherpaGuard(\"Level\", v, types)\n",
+			"new verifier(types, false, true, defaultOptions, { ..._sherpaTypeOptions, splitTypes: false }).verify(name, v, [name])",
+		},
+		nil,
+	)
+}
+
+func TestSafeParser(t *testing.T) {
+	checkFragments(t, Options{SafeParser: true},
+		[]string{
+			"export const safeParser = {\n\tUser: (v: any) => _sherpaSafeParse<User>(\"User\", v, types),\n",
+			"export type SafeParseResult<T> = ",
+			"const _sherpaSafeParse = ",
+		},
+		nil,
+	)
+	checkFragments(t, Options{}, nil, []string{"safeParser", "SafeParseResult"})
+}
+
+func TestRuntimeTypes(t *testing.T) {
+	checkFragments(t, Options{RuntimeTypes: "lazy"},
+		[]string{
+			"\nconst typeUser: Struct = {\"Name\":\"User\",",
+			"\tUser: (v: any) => parse(\"User\", v, {\"User\": typeUser, \"Kind\": typeKind, \"class0\": typeclass0}) as User,\n",
+			"\t\tconst types: TypenameMap = {\"Level\": typeLevel}\n",
+			"verifyArg(name, v, [name], true, false, namedTypes || {}, defaultOptions)",
+		},
+		[]string{"export const types: TypenameMap = "},
+	)
+	checkFragments(t, Options{RuntimeTypes: "none", Timestamp: "string"},
+		[]string{
+			"const verifyParams = (name: string, paramTypes: string[][], types: TypenameMap, options: ClientOptions, params: any[]): any[] => params\n",
+			"_sherpaCall(this.baseURL, this.authState, { ...this.options }, null, null, null, fn, params) as User\n",
+		},
+		[]string{"export const types: TypenameMap = ", "const typeUser", "export const parser", "export class verifier"},
+	)
+
+	// Without runtime types, options that need them are rejected.
+	for _, opts := range []Options{
+		{RuntimeTypes: "none"},
+		{RuntimeTypes: "none", Timestamp: "string", Guards: true},
+		{RuntimeTypes: "none", Timestamp: "string", BigInt: true},
+		{RuntimeTypes: "lazy", CloneEqual: true},
+		{RuntimeTypes: "bogus"},
+	} {
+		f, err := os.Open("testdata/api.json")
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		err = Generate(f, io.Discard, "api", opts)
+		f.Close()
+		if err == nil {
+			t.Errorf("generate with options %#v did not fail", opts)
+		}
+	}
+}
+
+func TestStandaloneFunctions(t *testing.T) {
+	checkFragments(t, Options{StandaloneFunctions: true},
+		[]string{
+			"export const Echo = async (clientConfig: ClientConfig, user: User, in0: string | null): Promise<User> => {\n",
+			"\treturn await _sherpaCallConfig(clientConfig, paramTypes, returnTypes, null, fn, params) as User\n",
+			// Names of standalone functions are module names, so keywords are renamed.
+			"export const delete0 = async (clientConfig: ClientConfig, ids: string[] | null, kind: Kind): Promise<void> => {\n",
+			"// - standalone function delete renamed to delete0: reserved word\n",
+			// Methods of the client are not renamed.
+			"\tasync delete(ids: string[] | null, kind: Kind): Promise<void> {\n",
+		},
+		nil,
+	)
+	checkFragments(t, Options{StandaloneFunctions: true, RuntimeTypes: "lazy"},
+		[]string{"\treturn await _sherpaCallConfig(clientConfig, paramTypes, returnTypes, types, fn, params) as User\n"},
+		nil,
+	)
+}
+
+func TestNamedParams(t *testing.T) {
+	checkFragments(t, Options{NamedParams: true},
+		[]string{
+			"\tasync Echo(args: {user: User, in: string | null}): Promise<User> {\n",
+			"\t\tconst params: any[] = [args.user, args.in]\n",
+			"\tasync Stats(args: {m: { [key: string]: number }, when: Date, x: any}): Promise<[number[] | null, Level]> {\n",
+		},
+		// Parameters are properties, so are not renamed.
+		[]string{"in0", "fn0"},
+	)
+}
+
+func TestResults(t *testing.T) {
+	checkFragments(t, Options{Results: "labeled-tuple"},
+		[]string{
+			"\tasync Login(name: string, fn0: number): Promise<[user: User, token: string]> {\n",
+			"\tasync Stats(m: { [key: string]: number }, when: Date, x: any): Promise<[r0: number[] | null, r1: Level]> {\n",
+		},
+		nil,
+	)
+	checkFragments(t, Options{Results: "object"},
+		[]string{
+			"\tasync Login(name: string, fn0: number): Promise<{user: User, token: string}> {\n",
+			".then((r: [User, string]) => ({user: r[0], token: r[1]})) as {user: User, token: string}\n",
+			// A single result is returned as is.
+			"\tasync Echo(user: User, in0: string | null): Promise<User> {\n",
+		},
+		nil,
+	)
+}
+
+func TestJSDoc(t *testing.T) {
+	checkFragments(t, Options{JSDoc: true},
+		[]string{
+			"\t/** ID of user. */\n\tID: number\n",
+			"\t/** Dash. */\n\t\"my-field\": class0 | null\n",
+			"\t/**\n\t * Echo returns its input.\n\t *\n\t * @param user Value of type {@link User}.\n\t * @param in0 Value of type string | null.\n\t * @returns Value of type {@link User}.\n\t */\n",
+			"\t * @deprecated Use Login2.\n\t * @param name Value of type string.\n\t * @param fn0 Value of type number.\n\t * @returns Tuple with user ({@link User}), token (string).\n",
+			// No @returns for functions without return values.
+			"\t/**\n\t * @param ids Value of type string[] | null.\n\t * @param kind Value of type {@link Kind}.\n\t */\n\tasync delete(",
+			"\t * @returns Tuple with number[] | null, {@link Level}.\n",
+		},
+		nil,
+	)
+	checkFragments(t, Options{JSDoc: true, NamedParams: true, Results: "object", StandaloneFunctions: true},
+		[]string{
+			" * @param clientConfig Client, or authentication state and options for the call.\n * @param args Parameters by name.\n * @param args.user Value of type {@link User}.\n * @param args.in Value of type string | null.\n",
+			" * @returns Object with r0 (number[] | null), r1 ({@link Level}).\n",
+		},
+		nil,
+	)
+}
+
+func TestWarnDeprecated(t *testing.T) {
+	checkFragments(t, Options{WarnDeprecated: true},
+		[]string{"\t\tconst fn: string = \"Login\"\n\t\t_sherpaWarnDeprecated(fn, \"Use Login2.\")\n", "const _sherpaWarnDeprecated = "},
+		nil,
+	)
+	checkFragments(t, Options{}, nil, []string{"_sherpaWarnDeprecated"})
+}
+
+func TestZeroValues(t *testing.T) {
+	// Fields of the User struct in testdata/api.json:
+	// ID int64, Name string, Created timestamp, Tags []string, Attrs {}nullable string,
+	// Kind Kind, Friend nullable User, Data []uint8, Other class.
+	tests := []struct {
+		opts   Options
+		fields string
+	}{
+		{Options{}, `ID: 0, Name: "", Created: new Date("0001-01-01T00:00:00Z"), Tags: [], Attrs: {}, Kind: Kind.Admin, Friend: null, Data: [], Other: newclass0()`},
+		{Options{SlicesNullable: true, MapsNullable: true}, `ID: 0, Name: "", Created: new Date("0001-01-01T00:00:00Z"), Tags: null, Attrs: null, Kind: Kind.Admin, Friend: null, Data: null, Other: newclass0()`},
+		{Options{BigInt: true, Timestamp: "string"}, `ID: 0n, Name: "", Created: "0001-01-01T00:00:00Z", Tags: [], Attrs: {}, Kind: Kind.Admin, Friend: null, Data: [], Other: newclass0()`},
+		{Options{Timestamp: "temporal"}, `ID: 0, Name: "", Created: Temporal.Instant.from("0001-01-01T00:00:00Z"), Tags: [], Attrs: {}, Kind: Kind.Admin, Friend: null, Data: [], Other: newclass0()`},
+		{Options{Enums: "union"}, `ID: 0, Name: "", Created: new Date("0001-01-01T00:00:00Z"), Tags: [], Attrs: {}, Kind: "admin", Friend: null, Data: [], Other: newclass0()`},
+		{Options{BytesToString: true}, `ID: 0, Name: "", Created: new Date("0001-01-01T00:00:00Z"), Tags: [], Attrs: {}, Kind: Kind.Admin, Friend: null, Data: "", Other: newclass0()`},
+		// Mapped types get the zero value of the original type, or null if it would have
+		// to be parsed.
+		{Options{TypeMappings: []TypeMapping{{Name: "Kind", Type: "string"}}}, `ID: 0, Name: "", Created: new Date("0001-01-01T00:00:00Z"), Tags: [], Attrs: {}, Kind: "admin" as any, Friend: null, Data: [], Other: newclass0()`},
+		{Options{TypeMappings: []TypeMapping{{Name: "Kind", Type: "KindClass", Parse: "(v: string) => new KindClass(v)"}}}, `ID: 0, Name: "", Created: new Date("0001-01-01T00:00:00Z"), Tags: [], Attrs: {}, Kind: null as any, Friend: null, Data: [], Other: newclass0()`},
+	}
+	for _, test := range tests {
+		test.opts.Constructors = true
+		buf := generateTestAPI(t, test.opts)
+		exp := "export const newUser = (partial?: Partial<User>): User => ({" + test.fields + ", ...partial})\n"
+		if !bytes.Contains(buf, []byte(exp)) {
+			t.Errorf("options %#v: generated code does not contain %q", test.opts, exp)
+		}
+	}
+}
+
+func TestCloneEqual(t *testing.T) {
+	checkFragments(t, Options{CloneEqual: true},
+		[]string{
+			"export const clone = <T>(name: string, v: T): T => _sherpaCloneTypewords([name], v)\n",
+			"export const equal = <T>(name: string, a: T, b: T): boolean => _sherpaEqualTypewords([name], a, b)\n",
+		},
+		nil,
+	)
+	checkFragments(t, Options{}, nil, []string{"export const clone", "export const equal"})
+}
+
+func TestReadonly(t *testing.T) {
+	checkFragments(t, Options{Readonly: true},
+		[]string{
+			"\treadonly ID: number  // ID of user.\n",
+			"\treadonly Tags: ReadonlyArray<string> | null\n",
+			"\treadonly Attrs: Readonly<Record<string, string | null>>\n",
+			"\treadonly \"my-field\": class0 | null  // Dash.\n",
+			"\tasync Login(name: string, fn0: number): Promise<readonly [User, string]> {\n",
+			"\tasync Stats(m: Readonly<Record<string, number>>, when: Date, x: any): Promise<readonly [ReadonlyArray<number> | null, Level]> {\n",
+		},
+		nil,
+	)
+}
+
+func TestUnknown(t *testing.T) {
+	checkFragments(t, Options{Unknown: true, SafeParser: true},
+		[]string{
+			"\tUser: (v: unknown) => parse(\"User\", v) as User,\n",
+			"\tUser: (v: unknown) => _sherpaSafeParse<User>(\"User\", v, types),\n",
+			"\tasync Stats(m: { [key: string]: number }, when: Date, x: unknown): Promise<[number[] | null, Level]> {\n",
+			"\t\tconst params: unknown[] = [m, when, x]\n",
+		},
+		[]string{"\tUser: (v: any) =>", "x: any"},
+	)
+}
+
+func TestSplitTypes(t *testing.T) {
+	// Outer has Middle has Inner has a timestamp, so all get In and Out types,
+	// whatever order they are checked in. Plain only has a string.
+	doc := testDoc(
+		`{"Name": "Get", "Docs": "", "Params": [{"Name": "p", "Typewords": ["Plain"]}], "Returns": [{"Name": "r0", "Typewords": ["Outer"]}]}`,
+		`{"Name": "Outer", "Docs": "", "Fields": [{"Name": "Middles", "Docs": "", "Typewords": ["[]", "Middle"]}]}, `+
+			`{"Name": "Middle", "Docs": "", "Fields": [{"Name": "Inner", "Docs": "", "Typewords": ["{}", "Inner"]}]}, `+
+			`{"Name": "Inner", "Docs": "", "Fields": [{"Name": "Time", "Docs": "", "Typewords": ["timestamp"]}]}, `+
+			`{"Name": "Plain", "Docs": "", "Fields": [{"Name": "Name", "Docs": "", "Typewords": ["nullable", "string"]}]}`,
+		``,
+	)
+	tests := []struct {
+		opts  Options
+		split []string
+	}{
+		{Options{}, []string{"Outer", "Middle", "Inner"}},
+		{Options{Timestamp: "string"}, nil},
+		{Options{Timestamp: "string", NullableOptional: true}, []string{"Plain"}},
+		{Options{Timestamp: "string", Readonly: true}, []string{"Outer", "Middle", "Inner", "Plain"}},
+	}
+	for _, test := range tests {
+		test.opts.SplitTypes = true
+		var b bytes.Buffer
+		if err := Generate(strings.NewReader(doc), &b, "api", test.opts); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		split := map[string]bool{}
+		for _, name := range test.split {
+			split[name] = true
+		}
+		for _, name := range []string{"Outer", "Middle", "Inner", "Plain"} {
+			for _, suffix := range []string{"In", "Out"} {
+				decl := "export interface " + name + suffix + " {"
+				if got := strings.Contains(b.String(), decl); got != split[name] {
+					t.Errorf("options %#v: got %v for declaration of %s%s, expected %v", test.opts, got, name, suffix, split[name])
+				}
+			}
+		}
+	}
+
+	// Split types determine the types, they cannot be changed with ClientOptions.
+	checkFragments(t, Options{SplitTypes: true},
+		[]string{
+			"\tasync Echo(user: UserIn, in0: string | null): Promise<UserOut> {\n",
+			"const _sherpaTypeOptions: _sherpaTypeOptions = {bigint: false, timestamp: \"date\", splitTypes: true}\n",
+			"let defaultOptions: ClientOptions = {slicesNullable: false, mapsNullable: false, nullableOptional: false}\n",
+		},
+		[]string{"splitTypes?:"},
+	)
+}
@@ -0,0 +1,809 @@
+// NOTE: GENERATED by github.com/mjl-/sherpats, DO NOT MODIFY
+
+// Identifiers renamed in this file:
+// - type class renamed to class0: typescript keyword
+// - parameter in renamed to in0: reserved word
+// - parameter fn renamed to fn0: name used by generated function
+
+// User is a user.
+// Multiple lines.
+export interface User {
+	ID: number  // ID of user.
+	Name: string
+	Created: Date
+	Tags: string[] | null
+	Attrs: { [key: string]: string | null }
+	Kind: Kind
+	Friend: User | null
+	Data: number[] | null
+	Other: class0
+}
+
+// Kind of user.
+export enum Kind {
+	Admin = "admin",  // Administrator.
+	default = "regular",
+}
+
+// Free-form string.
+export type Free = string
+
+// Class has a keyword name.
+export interface class0 {
+	type: string
+	"my-field": class0 | null  // Dash.
+}
+
+// Level of things.
+export enum Level {
+	Low = 1,  // Low level.
+	High = 2,
+}
+
+export const structTypes: {[typename: string]: boolean} = {"User":true,"class0":true}
+export const stringsTypes: {[typename: string]: boolean} = {"Free":true,"Kind":true}
+export const intsTypes: {[typename: string]: boolean} = {"Level":true}
+export const types: TypenameMap = {
+	"User": {"Name":"User","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Tags","Docs":"","Typewords":["[]","string"]},{"Name":"Attrs","Docs":"","Typewords":["{}","nullable","string"]},{"Name":"Kind","Docs":"","Typewords":["Kind"]},{"Name":"Friend","Docs":"","Typewords":["nullable","User"]},{"Name":"Data","Docs":"","Typewords":["[]","uint8"]},{"Name":"Other","Docs":"","Typewords":["class0"]}]},
+	"Kind": {"Name":"Kind","Docs":"","Values":[{"Name":"Admin","Value":"admin","Docs":""},{"Name":"default","Value":"regular","Docs":""}]},
+	"Free": {"Name":"Free","Docs":"","Values":[]},
+	"class0": {"Name":"class0","Docs":"","Fields":[{"Name":"type","Docs":"","Typewords":["string"]},{"Name":"my-field","Docs":"","Typewords":["nullable","class0"]}]},
+	"Level": {"Name":"Level","Docs":"","Values":[{"Name":"Low","Value":1,"Docs":""},{"Name":"High","Value":2,"Docs":""}]},
+}
+
+export const parser = {
+	User: (v: any) => parse("User", v) as User,
+	Kind: (v: any) => parse("Kind", v) as Kind,
+	Free: (v: any) => parse("Free", v) as Free,
+	class0: (v: any) => parse("class0", v) as class0,
+	Level: (v: any) => parse("Level", v) as Level,
+}
+
+// Example API.
+// Second line.
+//
+// # Sub
+// Subsection.
+let defaultOptions: ClientOptions = {slicesNullable: false, mapsNullable: false, nullableOptional: false}
+
+export class Client {
+	private baseURL: string
+	public authState: AuthState
+	public options: ClientOptions
+
+	constructor() {
+		this.authState = {}
+		this.options = {...defaultOptions}
+		this.baseURL = this.options.baseURL || defaultBaseURL
+	}
+
+	withAuthToken(token: string): Client {
+		const c = new Client()
+		c.authState.token = token
+		c.options = this.options
+		return c
+	}
+
+	withOptions(options: ClientOptions): Client {
+		const c = new Client()
+		c.authState = this.authState
+		c.options = { ...this.options, ...options }
+		c.baseURL = c.options.baseURL || defaultBaseURL
+		return c
+	}
+
+	// Echo returns its input.
+	async Echo(user: User, in0: string | null): Promise<User> {
+		const fn: string = "Echo"
+		const paramTypes: string[][] = [["User"],["nullable","string"]]
+		const returnTypes: string[][] = [["User"]]
+		const params: any[] = [user, in0]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, null, fn, params) as User
+	}
+
+	// Login logs in.
+	// 
+	// Deprecated: Use Login2.
+	async Login(name: string, fn0: number): Promise<[User, string]> {
+		const fn: string = "Login"
+		const paramTypes: string[][] = [["string"],["int64"]]
+		const returnTypes: string[][] = [["User"],["string"]]
+		const params: any[] = [name, fn0]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, null, fn, params) as [User, string]
+	}
+
+	async delete(ids: string[] | null, kind: Kind): Promise<void> {
+		const fn: string = "delete"
+		const paramTypes: string[][] = [["[]","int64s"],["Kind"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [ids, kind]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, null, fn, params) as void
+	}
+	// Stats.
+	async Stats(m: { [key: string]: number }, when: Date, x: any): Promise<[number[] | null, Level]> {
+		const fn: string = "Stats"
+		const paramTypes: string[][] = [["{}","float32"],["timestamp"],["any"]]
+		const returnTypes: string[][] = [["[]","uint8"],["Level"]]
+		const params: any[] = [m, when, x]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, null, fn, params) as [number[] | null, Level]
+	}
+}
+
+export const defaultBaseURL = (function() {
+	let p = location.pathname
+	if (p && p[p.length - 1] !== '/') {
+		let l = location.pathname.split('/')
+		l = l.slice(0, l.length - 1)
+		p = '/' + l.join('/') + '/'
+	}
+	return location.protocol + '//' + location.host + p + 'api/'
+})()
+
+// NOTE: code below is shared between github.com/mjl-/sherpaweb and github.com/mjl-/sherpats.
+// KEEP IN SYNC.
+
+export const supportedSherpaVersion = 1
+
+export interface Section {
+	Name: string
+	Docs: string
+	Functions: Function[]
+	Sections: Section[]
+	Structs: Struct[]
+	Ints: Ints[]
+	Strings: Strings[]
+	Version: string // only for top-level section
+	SherpaVersion: number // only for top-level section
+	SherpadocVersion: number // only for top-level section
+}
+
+export interface Function {
+	Name: string
+	Docs: string
+	Params: Arg[]
+	Returns: Arg[]
+}
+
+export interface Arg {
+	Name: string
+	Typewords: string[]
+}
+
+export interface Struct {
+	Name: string
+	Docs: string
+	Fields: Field[]
+}
+
+export interface Field {
+	Name: string
+	Docs: string
+	Typewords: string[]
+}
+
+export interface Ints {
+	Name: string
+	Docs: string
+	Values: {
+		Name: string
+		Value: number
+		Docs: string
+	}[] | null
+}
+
+export interface Strings {
+	Name: string
+	Docs: string
+	Values: {
+		Name: string
+		Value: string
+		Docs: string
+	}[] | null
+}
+
+export type NamedType = Struct | Strings | Ints
+export type TypenameMap = { [k: string]: NamedType }
+
+// verifyArg typechecks "v" against "typewords", returning a new (possibly modified) value for JSON-encoding.
+// toJS indicate if the data is coming into JS. If so, timestamps are turned into JS Dates. Otherwise, JS Dates are turned into strings.
+// allowUnknownKeys configures whether unknown keys in structs are allowed.
+// types are the named types of the API.
+export const verifyArg = (path: string, v: any, typewords: string[], toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): any => {
+	return new verifier(types, toJS, allowUnknownKeys, opts).verify(path, v, typewords)
+}
+
+// VerifyIssue is a problem with a value found during verification.
+export interface VerifyIssue {
+	path: string
+	expected: string
+	got: string
+	message: string // Including path.
+}
+
+// verifyAll is like verifyArg, but verifies the entire value and returns all
+// issues instead of throwing an exception for the first. The returned value is
+// only valid if there are no issues.
+export const verifyAll = (path: string, v: any, typewords: string[], toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): { value: any, issues: VerifyIssue[] } => {
+	return verifyAllCheck(path, v, _sherpaCheckTypewords(typewords), toJS, allowUnknownKeys, types, opts)
+}
+
+const verifyCheck = (path: string, v: any, check: _sherpaCheck, toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): any => {
+	return check(new verifier(types, toJS, allowUnknownKeys, opts), path, v)
+}
+
+const verifyAllCheck = (path: string, v: any, check: _sherpaCheck, toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): { value: any, issues: VerifyIssue[] } => {
+	const vf = new verifier(types, toJS, allowUnknownKeys, opts)
+	vf.issues = []
+	const value = vf.sub(path, v, check)
+	return { value: value, issues: vf.issues }
+}
+
+// _sherpaTypeOptions are the options the code was generated with that determine
+// the TypeScript types. Unlike ClientOptions, they cannot be changed at runtime.
+interface _sherpaTypeOptions {
+	bigint: boolean
+	timestamp: 'date' | 'string' | 'temporal'
+	splitTypes: boolean // Verify for In and Out types: parameters can have timestamps as string, missing values in results become null.
+}
+const _sherpaTypeOptions: _sherpaTypeOptions = {bigint: false, timestamp: "date", splitTypes: false}
+
+// parse verifies and converts v, a value of named type name as received from
+// the server. With lazy runtime types, there is no table with all types, and
+// namedTypes must have the type and the types it references.
+export const parse = (name: string, v: any, namedTypes?: TypenameMap): any => verifyArg(name, v, [name], true, false, namedTypes || types, defaultOptions)
+
+// _sherpaCheck verifies a value, like verifier.verify does for typewords. With
+// compiled checks, checks are built once when the module is loaded.
+export type _sherpaCheck = (vf: verifier, path: string, v: any) => any
+
+// _sherpaCompiledStruct has a check for each field of a struct, and the fields by name.
+interface _sherpaCompiledStruct {
+	fields: [string, _sherpaCheck][]
+	known: { [key: string]: boolean }
+}
+
+const _sherpaCheckTypewords = (typewords: string[]): _sherpaCheck => (vf, path, v) => vf.verify(path, v, typewords)
+
+// _sherpaStructChecks returns the checks for the fields of struct t, from their
+// typewords, or the compiled checks.
+const _sherpaStructChecks = (t: Struct): _sherpaCompiledStruct => {
+	const known: { [key: string]: boolean } = {}
+	for (const f of t.Fields) {
+		known[f.Name] = true
+	}
+	return { fields: t.Fields.map((f): [string, _sherpaCheck] => [f.Name, _sherpaCheckTypewords(f.Typewords)]), known: known }
+}
+
+// _sherpaChecks returns the checks for the parameters or results of function
+// name, from their typewords, or the compiled checks.
+const _sherpaChecks = (name: string, typewords: string[][], returns: boolean): _sherpaCheck[] => {
+	return typewords.map((tw) => _sherpaCheckTypewords(tw))
+}
+
+// Ranges of integer types: minimum, exclusive maximum and a description. The
+// bounds of 64 bit types can be represented exactly as number, unlike the
+// inclusive maximum. Large 64 bit integers from JSON are rounded when parsed as
+// number, the largest values round up to the exclusive maximum, so that bound is
+// allowed for numbers.
+const _sherpaIntRanges: { [w: string]: [number, number, string] } = {
+	int8: [-128, 128, '-128 to 127'],
+	uint8: [0, 256, '0 to 255'],
+	int16: [-32768, 32768, '-32768 to 32767'],
+	uint16: [0, 65536, '0 to 65535'],
+	int32: [-2147483648, 2147483648, '-2147483648 to 2147483647'],
+	uint32: [0, 4294967296, '0 to 4294967295'],
+	int64: [-9223372036854775808, 9223372036854775808, '-2^63 to 2^63-1'],
+	uint64: [0, 18446744073709551616, '0 to 2^64-1'],
+}
+
+// Timestamps as sent by the server, with optional fraction of seconds.
+const _sherpaRFC3339 = /^[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[-+][0-9]{2}:[0-9]{2})$/
+
+// Thrown by the verifier when collecting issues, to stop verifying a value after
+// its issue has been recorded.
+const verifyAbort = {}
+
+// gotString formats a value for an error message. JSON.stringify cannot handle
+// BigInt, turns NaN and Infinity into null, and returns undefined for undefined.
+const gotString = (v: any): string => typeof v === 'bigint' ? v + 'n' : typeof v === 'number' || v === undefined ? '' + v : JSON.stringify(v)
+
+export class verifier {
+	// If not null, issues are collected instead of throwing an exception for the first.
+	issues: VerifyIssue[] | null = null
+
+	constructor(private types: TypenameMap, private toJS: boolean, private allowUnknownKeys: boolean, private opts: ClientOptions, private typeOpts: _sherpaTypeOptions = _sherpaTypeOptions) {
+	}
+
+	// sub verifies a nested value. When collecting issues, an issue in the value
+	// does not stop verification of the values next to it.
+	sub(path: string, v: any, check: _sherpaCheck): any {
+		if (!this.issues) {
+			return check(this, path, v)
+		}
+		try {
+			return check(this, path, v)
+		} catch (err) {
+			if (err !== verifyAbort) {
+				throw err
+			}
+		}
+	}
+
+	error(path: string, v: any, msg: string, expected: string, got?: string): never {
+		if (path != '') {
+			msg = path + ': ' + msg
+		}
+		if (this.issues) {
+			this.issues.push({ path: path, expected: expected, got: got === undefined ? gotString(v) : got, message: msg })
+			throw verifyAbort
+		}
+		throw new Error(msg)
+	}
+
+	ensure(path: string, v: any, ok: boolean, expect: string): any {
+		if (!ok) {
+			this.error(path, v, 'got ' + gotString(v) + ', expected ' + expect, expect)
+		}
+		return v
+	}
+
+	verify(path: string, v: any, typewords: string[]): any {
+		const w = typewords[0]
+		if (typeof w !== 'string') {
+			this.error(path, v, 'bad typewords', 'typewords')
+		}
+		const rest = typewords.slice(1)
+
+		switch (w) {
+		case 'nullable':
+			return this.nullable(path, v, _sherpaCheckTypewords(rest))
+		case '[]':
+			return this.array(path, v, _sherpaCheckTypewords(rest))
+		case '{}':
+			return this.map(path, v, _sherpaCheckTypewords(rest))
+		}
+
+		this.ensure(path, v, rest.length == 0, "empty typewords")
+		return this.base(path, v, w)
+	}
+
+	// missing returns null or undefined v. With split types, results have null
+	// instead of undefined, as in the Out types.
+	missing(v: any): any {
+		return v === undefined && this.toJS && this.typeOpts.splitTypes ? null : v
+	}
+
+	nullable(path: string, v: any, check: _sherpaCheck): any {
+		if (v === null || v === undefined && this.opts.nullableOptional) {
+			return this.missing(v)
+		}
+		return check(this, path, v)
+	}
+
+	array(path: string, v: any, check: _sherpaCheck): any {
+		if (v === null && this.opts.slicesNullable || v === undefined && this.opts.slicesNullable && this.opts.nullableOptional) {
+			return this.missing(v)
+		}
+		this.ensure(path, v, Array.isArray(v), "array")
+		return v.map((e: any, i: number) => this.sub(path + '[' + i + ']', e, check))
+	}
+
+	map(path: string, v: any, check: _sherpaCheck): any {
+		if (v === null && this.opts.mapsNullable || v === undefined && this.opts.mapsNullable && this.opts.nullableOptional) {
+			return this.missing(v)
+		}
+		this.ensure(path, v, v !== null || typeof v === 'object', "object")
+		const r: any = {}
+		for (const k in v) {
+			r[k] = this.sub(path + '.' + k, v[k], check)
+		}
+		return r
+	}
+
+	// bigint returns v as BigInt when coming into JS. When going to JSON, it returns
+	// a BigInt for int64/uint64, to be written as JSON number, and a string for
+	// int64s/uint64s.
+	bigint(path: string, v: any, w: string, stringEncoded: boolean): any {
+		const t = typeof v
+		let b: bigint
+		if (this.toJS) {
+			this.ensure(path, v, t === 'bigint' || t === 'number' && Number.isInteger(v) || t === 'string' && /^-?[0-9]+$/.test(v), 'integer')
+			b = BigInt(v)
+		} else {
+			this.ensure(path, v, t === 'bigint', 'bigint')
+			b = v
+		}
+		const [min, max, descr] = _sherpaIntRanges[w.replace(/s$/, '')]
+		this.ensure(path, v, b >= BigInt(min) && b < BigInt(max), w + ' (' + descr + ')')
+		if (this.toJS || !stringEncoded) {
+			return b
+		}
+		return b.toString()
+	}
+
+	// base verifies a value of a single typeword, which is not nullable, [] or {}.
+	base(path: string, v: any, w: string): any {
+		const t = typeof v
+
+		switch (w) {
+		case 'any':
+			return v
+		case 'bool':
+			this.ensure(path, v, t === 'boolean', 'bool')
+			return v
+		case 'int8':
+		case 'uint8':
+		case 'int16':
+		case 'uint16':
+		case 'int32':
+		case 'uint32':
+		case 'int64':
+		case 'uint64':
+			if (this.typeOpts.bigint && (w === 'int64' || w === 'uint64')) {
+				return this.bigint(path, v, w, false)
+			}
+			const [min, max, descr] = _sherpaIntRanges[w]
+			const rounded = w === 'int64' || w === 'uint64'
+			this.ensure(path, v, t === 'number' && Number.isInteger(v) && v >= min && (v < max || rounded && v === max), w + ' (' + descr + ')')
+			return v
+		case 'float32':
+		case 'float64':
+			if (t === 'bigint' && this.toJS) {
+				// Large integer from JSON, see _sherpaParseJSONBigInt.
+				v = Number(v)
+			} else {
+				// NaN and Infinity cannot be represented in JSON.
+				this.ensure(path, v, t === 'number' && Number.isFinite(v), w + ' (finite number)')
+			}
+			if (w === 'float32') {
+				// Values are rounded to the nearest float32, like Go's largest float32
+				// 3.4028235e+38 from its JSON package. Too large values become Infinity.
+				this.ensure(path, v, Number.isFinite(Math.fround(v)), 'float32 (in range of float32)')
+			}
+			return v
+		case 'int64s':
+		case 'uint64s':
+			if (this.typeOpts.bigint) {
+				return this.bigint(path, v, w, true)
+			}
+			this.ensure(path, v, t === 'number' && Number.isInteger(v) || t === 'string', 'integer fitting in float without precision loss, or string')
+			return '' + v
+		case 'string':
+			this.ensure(path, v, t === 'string', 'string')
+			return v
+		case 'timestamp':
+			const mode = this.typeOpts.timestamp
+			// Temporal through globalThis, it is only required for the "temporal" mode.
+			const Temporal = mode === 'temporal' ? (globalThis as any).Temporal : undefined
+			if (!this.toJS && mode !== 'string' && t === 'string' && this.typeOpts.splitTypes) {
+				// With split types, parameters can have timestamps as string, as in the In types.
+				if (mode === 'date') {
+					this.ensure(path, v, !isNaN(new Date(v).getTime()), 'string, with timestamp')
+					return v
+				}
+				return this.ensure(path, v, _sherpaRFC3339.test(v), 'string, with RFC3339 timestamp')
+			}
+			if (this.toJS && mode === 'date') {
+				// Any string that is a valid date, as before the timestamp modes.
+				this.ensure(path, v, t === 'string', 'string, with timestamp')
+			} else if (this.toJS || mode === 'string') {
+				// Strings are kept or parsed as is, so must be exact.
+				this.ensure(path, v, t === 'string' && _sherpaRFC3339.test(v), 'string, with RFC3339 timestamp')
+			}
+			if (mode === 'string') {
+				return v
+			} else if (mode === 'temporal') {
+				if (this.toJS) {
+					try {
+						return Temporal.Instant.from(v)
+					} catch (err) {
+						this.error(path, v, 'invalid timestamp ' + v, 'valid timestamp')
+					}
+				}
+				this.ensure(path, v, v instanceof Temporal.Instant, 'Temporal.Instant')
+				return v.toString()
+			} else if (this.toJS) {
+				const d = new Date(v)
+				if (d instanceof Date && !isNaN(d.getTime())) {
+					return d
+				}
+				this.error(path, v, 'invalid date ' + v, 'valid date')
+			} else {
+				this.ensure(path, v, v instanceof Date && !isNaN(v.getTime()), 'valid Date')
+				return v.toISOString()
+			}
+		}
+
+		// We're left with named types.
+		return this.named(path, v, w)
+	}
+
+	named(path: string, v: any, w: string): any {
+		const nt = this.types[w]
+		if (!nt) {
+			this.error(path, v, 'unknown type ' + w, 'known type')
+		}
+		if (v === null) {
+			this.error(path, v, 'bad value ' + v + ' for named type ' + w, w)
+		}
+
+		if (structTypes[nt.Name]) {
+			const t = nt as Struct
+			if (typeof v !== 'object') {
+				this.error(path, v, 'bad value ' + v + ' for struct ' + w, 'struct ' + w)
+			}
+
+			const cs = _sherpaStructChecks(t)
+			const r: any = {}
+			for (const [name, check] of cs.fields) {
+				r[name] = this.sub(path + '.' + name, v[name], check)
+			}
+			// If going to JSON also verify no unknown fields are present.
+			if (!this.allowUnknownKeys) {
+				const known = cs.known
+				const unknown = Object.keys(v).filter((k) => !known[k])
+				if (unknown.length > 0) {
+					this.error(path, v, 'unknown key ' + unknown.join(', ') + ' for struct ' + w, 'known keys for struct ' + w, unknown.join(', '))
+				}
+			}
+			return r
+		} else if (stringsTypes[nt.Name]) {
+			const t = nt as Strings
+			if (typeof v !== 'string') {
+				this.error(path, v, 'mistyped value ' + v + ' for named strings ' + t.Name, 'string')
+			}
+			if (!t.Values || t.Values.length === 0) {
+				return v
+			}
+			for (const sv of t.Values) {
+				if (sv.Value === v) {
+					return v
+				}
+			}
+			this.error(path, v, 'unknown value ' + v + ' for named strings ' + t.Name, 'value of named strings ' + t.Name)
+		} else if (intsTypes[nt.Name]) {
+			const t = nt as Ints
+			if (typeof v !== 'number' || !Number.isInteger(v)) {
+				this.error(path, v, 'mistyped value ' + v + ' for named ints ' + t.Name, 'integer')
+			}
+			if (!t.Values || t.Values.length === 0) {
+				return v
+			}
+			for (const sv of t.Values) {
+				if (sv.Value === v) {
+					return v
+				}
+			}
+			this.error(path, v, 'unknown value ' + v + ' for named ints ' + t.Name, 'value of named ints ' + t.Name)
+		} else {
+			throw new Error('unexpected named type ' + nt)
+		}
+	}
+}
+
+// verifyParams returns the parameters for a call of function name, verified and
+// converted for JSON. An exception is thrown for invalid parameters.
+const verifyParams = (name: string, paramTypes: string[][], types: TypenameMap, options: ClientOptions, params: any[]): any[] => {
+	const checks = _sherpaChecks(name, paramTypes, false)
+	return params.map((v: any, index: number) => verifyCheck('params[' + index + ']', v, checks[index], false, false, types, options))
+}
+
+// verifyResult returns the result of a call of function name, verified and
+// converted for JS. An exception is thrown for an invalid result.
+const verifyResult = (name: string, returnTypes: string[][], types: TypenameMap, options: ClientOptions, result: any): any => {
+	const checks = _sherpaChecks(name, returnTypes, true)
+	if (returnTypes.length === 0) {
+		if (result) {
+			throw new Error('function ' + name + ' returned a value while prototype says it returns "void"')
+		}
+		return result
+	} else if (returnTypes.length === 1) {
+		return verifyCheck('result', result, checks[0], true, true, types, options)
+	}
+	if (result.length != returnTypes.length) {
+		throw new Error('wrong number of values returned by ' + name + ', saw ' + result.length + ' != expected ' + returnTypes.length)
+	}
+	return result.map((v: any, index: number) => verifyCheck('result[' + index + ']', v, checks[index], true, true, types, options))
+}
+
+// resultIssues verifies an invalid result again, gathering all issues, for
+// finding all differences between server and client at once.
+const resultIssues = (name: string, returnTypes: string[][], types: TypenameMap, options: ClientOptions, result: any): VerifyIssue[] => {
+	const checks = _sherpaChecks(name, returnTypes, true)
+	let issues: VerifyIssue[] = []
+	if (returnTypes.length === 1) {
+		issues = verifyAllCheck('result', result, checks[0], true, true, types, options).issues
+	} else if (Array.isArray(result) && result.length === returnTypes.length) {
+		result.forEach((v: any, index: number) => {
+			issues.push(...verifyAllCheck('result[' + index + ']', v, checks[index], true, true, types, options).issues)
+		})
+	}
+	return issues
+}
+
+export interface ClientOptions {
+	baseURL?: string
+	aborter?: {abort?: () => void}
+	timeoutMsec?: number
+	skipParamCheck?: boolean
+	skipReturnCheck?: boolean
+	slicesNullable?: boolean
+	mapsNullable?: boolean
+	nullableOptional?: boolean
+	csrfHeader?: string
+	login?: (reason: string) => Promise<string>
+}
+
+// ClientConfig is the configuration for calling standalone functions. A Client
+// can be used as ClientConfig. Without authState, tokens from logins, see
+// ClientOptions.login, are not kept between calls.
+export interface ClientConfig {
+	authState?: AuthState
+	options?: ClientOptions
+}
+
+export interface AuthState {
+	token?: string // For csrf request header.
+	loginPromise?: Promise<void> // To let multiple API calls wait for a single login attempt, not each opening a login popup.
+}
+
+// _sherpaCallConfig calls a function for a standalone function, with the default
+// options for fields not set in config.
+const _sherpaCallConfig = async (config: ClientConfig, paramTypes: string[][] | null, returnTypes: string[][] | null, fnTypes: TypenameMap | null, name: string, params: any[]): Promise<any> => {
+	const options: ClientOptions = { ...defaultOptions, ...config.options }
+	return await _sherpaCall(options.baseURL || defaultBaseURL, config.authState || {}, options, paramTypes, returnTypes, fnTypes, name, params)
+}
+
+// Without runtime type information, paramTypes and returnTypes are null and
+// parameters and results are not verified. With lazy runtime types, fnTypes
+// are the named types referenced by the function, otherwise it is null.
+const _sherpaCall = async (baseURL: string, authState: AuthState, options: ClientOptions, paramTypes: string[][] | null, returnTypes: string[][] | null, fnTypes: TypenameMap | null, name: string, params: any[]): Promise<any> => {
+	const namedTypes = fnTypes || types
+	if (!options.skipParamCheck && paramTypes) {
+		if (params.length !== paramTypes.length) {
+			return Promise.reject({ message: 'wrong number of parameters in sherpa call, saw ' + params.length + ' != expected ' + paramTypes.length })
+		}
+		params = verifyParams(name, paramTypes, namedTypes, options, params)
+	}
+	const simulate = async (json: string) => {
+		const config = JSON.parse(json || 'null') || {}
+		const waitMinMsec = config.waitMinMsec || 0
+		const waitMaxMsec = config.waitMaxMsec || 0
+		const wait = Math.random() * (waitMaxMsec - waitMinMsec)
+		const failRate = config.failRate || 0
+		return new Promise<void>((resolve, reject) => {
+			if (options.aborter) {
+				options.aborter.abort = () => {
+					reject({ message: 'call to ' + name + ' aborted by user', code: 'sherpa:aborted' })
+					reject = resolve = () => { }
+				}
+			}
+			setTimeout(() => {
+				const r = Math.random()
+				if (r < failRate) {
+					reject({ message: 'injected failure on ' + name, code: 'server:injected' })
+				} else {
+					resolve()
+				}
+				reject = resolve = () => { }
+			}, waitMinMsec + wait)
+		})
+	}
+	// Only simulate when there is a debug string. Otherwise it would always interfere
+	// with setting options.aborter.
+	let json: string = ''
+	try {
+		json = window.localStorage.getItem('sherpats-debug') || ''
+	} catch (err) {}
+	if (json) {
+		await simulate(json)
+	}
+
+	const fn = (resolve: (v: any) => void, reject: (v: any) => void) => {
+		let resolve1 = (v: any) => {
+			resolve(v)
+			resolve1 = () => { }
+			reject1 = () => { }
+		}
+		let reject1 = (v: { code: string, message: string, issues?: any[] }) => {
+			if ((v.code === 'user:noAuth' || v.code === 'user:badAuth')  && options.login) {
+				const login = options.login
+				if (!authState.loginPromise) {
+					authState.loginPromise = new Promise((aresolve, areject) => {
+						login(v.code === 'user:badAuth' ? (v.message || '') : '')
+						.then((token) => {
+							authState.token = token
+							authState.loginPromise = undefined
+							aresolve()
+						}, (err: any) => {
+							authState.loginPromise = undefined
+							areject(err)
+						})
+					})
+				}
+				authState.loginPromise
+				.then(() => {
+					fn(resolve, reject)
+				}, (err: any) => {
+					reject(err)
+				})
+				return
+			}
+			reject(v)
+			resolve1 = () => { }
+			reject1 = () => { }
+		}
+
+		const url = baseURL + name
+		const req = new window.XMLHttpRequest()
+		if (options.aborter) {
+			options.aborter.abort = () => {
+				req.abort()
+				reject1({ code: 'sherpa:aborted', message: 'request aborted' })
+			}
+		}
+		req.open('POST', url, true)
+		if (options.csrfHeader && authState.token) {
+			req.setRequestHeader(options.csrfHeader, authState.token)
+		}
+		if (options.timeoutMsec) {
+			req.timeout = options.timeoutMsec
+		}
+		req.onload = () => {
+			if (req.status !== 200) {
+				if (req.status === 404) {
+					reject1({ code: 'sherpa:badFunction', message: 'function does not exist' })
+				} else {
+					reject1({ code: 'sherpa:http', message: 'error calling function, HTTP status: ' + req.status })
+				}
+				return
+			}
+
+			let resp: any
+			try {
+				resp = JSON.parse(req.responseText)
+			} catch (err) {
+				reject1({ code: 'sherpa:badResponse', message: 'bad JSON from server' })
+				return
+			}
+			if (resp && resp.error) {
+				const err = resp.error
+				reject1({ code: err.code, message: err.message })
+				return
+			} else if (!resp || !resp.hasOwnProperty('result')) {
+				reject1({ code: 'sherpa:badResponse', message: "invalid sherpa response object, missing 'result'" })
+				return
+			}
+
+			if (options.skipReturnCheck || !returnTypes) {
+				resolve1(resp.result)
+				return
+			}
+			let result = resp.result
+			try {
+				result = verifyResult(name, returnTypes, namedTypes, options, result)
+			} catch (err) {
+				let errmsg = 'bad types'
+				if (err instanceof Error) {
+					errmsg = err.message
+				}
+				reject1({ code: 'sherpa:badTypes', message: errmsg, issues: resultIssues(name, returnTypes, namedTypes, options, resp.result) })
+			}
+			resolve1(result)
+		}
+		req.onerror = () => {
+			reject1({ code: 'sherpa:connection', message: 'connection failed' })
+		}
+		req.ontimeout = () => {
+			reject1({ code: 'sherpa:timeout', message: 'request timeout' })
+		}
+		req.setRequestHeader('Content-Type', 'application/json')
+		try {
+			req.send(JSON.stringify({ params: params }))
+		} catch (err) {
+			reject1({ code: 'sherpa:badData', message: 'cannot marshal to JSON' })
+		}
+	}
+	return await new Promise(fn)
+}
+
@@ -66,8 +66,14 @@ export interface Strings {
 
 export type NamedType = Struct | Strings | Ints
 export type TypenameMap = { [k: string]: NamedType }
+`
 
-// verifyArg typechecks "v" against "typewords", returning a new (possibly modified) value for JSON-encoding.
+// verifierTS is the runtime verification of values, left out when generating
+// without runtime type information. VERIFYHOOKS is replaced with verifyHooksTS
+// with type mappings. COMPILEDSTRUCT and COMPILEDSIGNATURE are replaced for
+// compiled checks, ALLTYPES as for clientTS. TYPEOPTIONS is replaced with the
+// options for _sherpaTypeOptions.
+const verifierTS = `// verifyArg typechecks "v" against "typewords", returning a new (possibly modified) value for JSON-encoding.
 // toJS indicate if the data is coming into JS. If so, timestamps are turned into JS Dates. Otherwise, JS Dates are turned into strings.
 // allowUnknownKeys configures whether unknown keys in structs are allowed.
 // types are the named types of the API.
@@ -75,67 +81,226 @@ export const verifyArg = (path: string, v: any, typewords: string[], toJS: boole
 	return new verifier(types, toJS, allowUnknownKeys, opts).verify(path, v, typewords)
 }
 
-export const parse = (name: string, v: any): any => verifyArg(name, v, [name], true, false, types, defaultOptions)
+// VerifyIssue is a problem with a value found during verification.
+export interface VerifyIssue {
+	path: string
+	expected: string
+	got: string
+	message: string // Including path.
+}
+
+// verifyAll is like verifyArg, but verifies the entire value and returns all
+// issues instead of throwing an exception for the first. The returned value is
+// only valid if there are no issues.
+export const verifyAll = (path: string, v: any, typewords: string[], toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): { value: any, issues: VerifyIssue[] } => {
+	return verifyAllCheck(path, v, _sherpaCheckTypewords(typewords), toJS, allowUnknownKeys, types, opts)
+}
+
+const verifyCheck = (path: string, v: any, check: _sherpaCheck, toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): any => {
+	return check(new verifier(types, toJS, allowUnknownKeys, opts), path, v)
+}
+
+const verifyAllCheck = (path: string, v: any, check: _sherpaCheck, toJS: boolean, allowUnknownKeys: boolean, types: TypenameMap, opts: ClientOptions): { value: any, issues: VerifyIssue[] } => {
+	const vf = new verifier(types, toJS, allowUnknownKeys, opts)
+	vf.issues = []
+	const value = vf.sub(path, v, check)
+	return { value: value, issues: vf.issues }
+}
+
+// _sherpaTypeOptions are the options the code was generated with that determine
+// the TypeScript types. Unlike ClientOptions, they cannot be changed at runtime.
+interface _sherpaTypeOptions {
+	bigint: boolean
+	timestamp: 'date' | 'string' | 'temporal'
+	splitTypes: boolean // Verify for In and Out types: parameters can have timestamps as string, missing values in results become null.
+}
+const _sherpaTypeOptions: _sherpaTypeOptions = TYPEOPTIONS
+
+// parse verifies and converts v, a value of named type name as received from
+// the server. With lazy runtime types, there is no table with all types, and
+// namedTypes must have the type and the types it references.
+export const parse = (name: string, v: any, namedTypes?: TypenameMap): any => verifyArg(name, v, [name], true, false, namedTypes || ALLTYPES, defaultOptions)
+
+// _sherpaCheck verifies a value, like verifier.verify does for typewords. With
+// compiled checks, checks are built once when the module is loaded.
+export type _sherpaCheck = (vf: verifier, path: string, v: any) => any
+
+// _sherpaCompiledStruct has a check for each field of a struct, and the fields by name.
+interface _sherpaCompiledStruct {
+	fields: [string, _sherpaCheck][]
+	known: { [key: string]: boolean }
+}
+
+const _sherpaCheckTypewords = (typewords: string[]): _sherpaCheck => (vf, path, v) => vf.verify(path, v, typewords)
 
-class verifier {
-	constructor(private types: TypenameMap, private toJS: boolean, private allowUnknownKeys: boolean, private opts: ClientOptions) {
+// _sherpaStructChecks returns the checks for the fields of struct t, from their
+// typewords, or the compiled checks.
+const _sherpaStructChecks = (t: Struct): _sherpaCompiledStruct => {
+	COMPILEDSTRUCT
+	const known: { [key: string]: boolean } = {}
+	for (const f of t.Fields) {
+		known[f.Name] = true
 	}
+	return { fields: t.Fields.map((f): [string, _sherpaCheck] => [f.Name, _sherpaCheckTypewords(f.Typewords)]), known: known }
+}
 
-	verify(path: string, v: any, typewords: string[]): any {
-		typewords = typewords.slice(0)
-		const ww = typewords.shift()
+// _sherpaChecks returns the checks for the parameters or results of function
+// name, from their typewords, or the compiled checks.
+const _sherpaChecks = (name: string, typewords: string[][], returns: boolean): _sherpaCheck[] => {
+	COMPILEDSIGNATURE
+	return typewords.map((tw) => _sherpaCheckTypewords(tw))
+}
+
+// Ranges of integer types: minimum, exclusive maximum and a description. The
+// bounds of 64 bit types can be represented exactly as number, unlike the
+// inclusive maximum. Large 64 bit integers from JSON are rounded when parsed as
+// number, the largest values round up to the exclusive maximum, so that bound is
+// allowed for numbers.
+const _sherpaIntRanges: { [w: string]: [number, number, string] } = {
+	int8: [-128, 128, '-128 to 127'],
+	uint8: [0, 256, '0 to 255'],
+	int16: [-32768, 32768, '-32768 to 32767'],
+	uint16: [0, 65536, '0 to 65535'],
+	int32: [-2147483648, 2147483648, '-2147483648 to 2147483647'],
+	uint32: [0, 4294967296, '0 to 4294967295'],
+	int64: [-9223372036854775808, 9223372036854775808, '-2^63 to 2^63-1'],
+	uint64: [0, 18446744073709551616, '0 to 2^64-1'],
+}
+
+// Timestamps as sent by the server, with optional fraction of seconds.
+const _sherpaRFC3339 = /^[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[-+][0-9]{2}:[0-9]{2})$/
+
+// Thrown by the verifier when collecting issues, to stop verifying a value after
+// its issue has been recorded.
+const verifyAbort = {}
+
+// gotString formats a value for an error message. JSON.stringify cannot handle
+// BigInt, turns NaN and Infinity into null, and returns undefined for undefined.
+const gotString = (v: any): string => typeof v === 'bigint' ? v + 'n' : typeof v === 'number' || v === undefined ? '' + v : JSON.stringify(v)
+
+export class verifier {
+	// If not null, issues are collected instead of throwing an exception for the first.
+	issues: VerifyIssue[] | null = null
+
+	constructor(private types: TypenameMap, private toJS: boolean, private allowUnknownKeys: boolean, private opts: ClientOptions, private typeOpts: _sherpaTypeOptions = _sherpaTypeOptions) {
+	}
 
-		const error = (msg: string) => {
-			if (path != '') {
-				msg = path + ': ' + msg
+	// sub verifies a nested value. When collecting issues, an issue in the value
+	// does not stop verification of the values next to it.
+	sub(path: string, v: any, check: _sherpaCheck): any {
+		if (!this.issues) {
+			return check(this, path, v)
+		}
+		try {
+			return check(this, path, v)
+		} catch (err) {
+			if (err !== verifyAbort) {
+				throw err
 			}
-			throw new Error(msg)
 		}
+	}
 
-		if (typeof ww !== 'string') {
-			error('bad typewords')
-			return // should not be necessary, typescript doesn't see error always throws an exception?
+	error(path: string, v: any, msg: string, expected: string, got?: string): never {
+		if (path != '') {
+			msg = path + ': ' + msg
 		}
-		const w: string = ww
+		if (this.issues) {
+			this.issues.push({ path: path, expected: expected, got: got === undefined ? gotString(v) : got, message: msg })
+			throw verifyAbort
+		}
+		throw new Error(msg)
+	}
 
-		const ensure = (ok: boolean, expect: string): any => {
-			if (!ok) {
-				error('got ' + JSON.stringify(v) + ', expected ' + expect)
-			}
-			return v
+	ensure(path: string, v: any, ok: boolean, expect: string): any {
+		if (!ok) {
+			this.error(path, v, 'got ' + gotString(v) + ', expected ' + expect, expect)
+		}
+		return v
+	}
+
+	verify(path: string, v: any, typewords: string[]): any {
+		const w = typewords[0]
+		if (typeof w !== 'string') {
+			this.error(path, v, 'bad typewords', 'typewords')
 		}
+		const rest = typewords.slice(1)
 
 		switch (w) {
 		case 'nullable':
-			if (v === null || v === undefined && this.opts.nullableOptional) {
-				return v
-			}
-			return this.verify(path, v, typewords)
+			return this.nullable(path, v, _sherpaCheckTypewords(rest))
 		case '[]':
-			if (v === null && this.opts.slicesNullable || v === undefined && this.opts.slicesNullable && this.opts.nullableOptional) {
-				return v
-			}
-			ensure(Array.isArray(v), "array")
-			return v.map((e: any, i: number) => this.verify(path + '[' + i + ']', e, typewords))
+			return this.array(path, v, _sherpaCheckTypewords(rest))
 		case '{}':
-			if (v === null && this.opts.mapsNullable || v === undefined && this.opts.mapsNullable && this.opts.nullableOptional) {
-				return v
-			}
-			ensure(v !== null || typeof v === 'object', "object")
-			const r: any = {}
-			for (const k in v) {
-				r[k] = this.verify(path + '.' + k, v[k], typewords)
-			}
-			return r
+			return this.map(path, v, _sherpaCheckTypewords(rest))
 		}
 
-		ensure(typewords.length == 0, "empty typewords")
+		this.ensure(path, v, rest.length == 0, "empty typewords")
+		return this.base(path, v, w)
+	}
+
+	// missing returns null or undefined v. With split types, results have null
+	// instead of undefined, as in the Out types.
+	missing(v: any): any {
+		return v === undefined && this.toJS && this.typeOpts.splitTypes ? null : v
+	}
+
+	nullable(path: string, v: any, check: _sherpaCheck): any {
+		if (v === null || v === undefined && this.opts.nullableOptional) {
+			return this.missing(v)
+		}
+		return check(this, path, v)
+	}
+
+	array(path: string, v: any, check: _sherpaCheck): any {
+		if (v === null && this.opts.slicesNullable || v === undefined && this.opts.slicesNullable && this.opts.nullableOptional) {
+			return this.missing(v)
+		}
+		this.ensure(path, v, Array.isArray(v), "array")
+		return v.map((e: any, i: number) => this.sub(path + '[' + i + ']', e, check))
+	}
+
+	map(path: string, v: any, check: _sherpaCheck): any {
+		if (v === null && this.opts.mapsNullable || v === undefined && this.opts.mapsNullable && this.opts.nullableOptional) {
+			return this.missing(v)
+		}
+		this.ensure(path, v, v !== null || typeof v === 'object', "object")
+		const r: any = {}
+		for (const k in v) {
+			r[k] = this.sub(path + '.' + k, v[k], check)
+		}
+		return r
+	}
+
+	// bigint returns v as BigInt when coming into JS. When going to JSON, it returns
+	// a BigInt for int64/uint64, to be written as JSON number, and a string for
+	// int64s/uint64s.
+	bigint(path: string, v: any, w: string, stringEncoded: boolean): any {
 		const t = typeof v
+		let b: bigint
+		if (this.toJS) {
+			this.ensure(path, v, t === 'bigint' || t === 'number' && Number.isInteger(v) || t === 'string' && /^-?[0-9]+$/.test(v), 'integer')
+			b = BigInt(v)
+		} else {
+			this.ensure(path, v, t === 'bigint', 'bigint')
+			b = v
+		}
+		const [min, max, descr] = _sherpaIntRanges[w.replace(/s$/, '')]
+		this.ensure(path, v, b >= BigInt(min) && b < BigInt(max), w + ' (' + descr + ')')
+		if (this.toJS || !stringEncoded) {
+			return b
+		}
+		return b.toString()
+	}
+
+	// base verifies a value of a single typeword, which is not nullable, [] or {}.
+	base(path: string, v: any, w: string): any {
+		const t = typeof v
+
 		switch (w) {
 		case 'any':
 			return v
 		case 'bool':
-			ensure(t === 'boolean', 'bool')
+			this.ensure(path, v, t === 'boolean', 'bool')
 			return v
 		case 'int8':
 		case 'uint8':
@@ -145,70 +310,119 @@ class verifier {
 		case 'uint32':
 		case 'int64':
 		case 'uint64':
-			ensure(t === 'number' && Number.isInteger(v), 'integer')
+			if (this.typeOpts.bigint && (w === 'int64' || w === 'uint64')) {
+				return this.bigint(path, v, w, false)
+			}
+			const [min, max, descr] = _sherpaIntRanges[w]
+			const rounded = w === 'int64' || w === 'uint64'
+			this.ensure(path, v, t === 'number' && Number.isInteger(v) && v >= min && (v < max || rounded && v === max), w + ' (' + descr + ')')
 			return v
 		case 'float32':
 		case 'float64':
-			ensure(t === 'number', 'float')
+			if (t === 'bigint' && this.toJS) {
+				// Large integer from JSON, see _sherpaParseJSONBigInt.
+				v = Number(v)
+			} else {
+				// NaN and Infinity cannot be represented in JSON.
+				this.ensure(path, v, t === 'number' && Number.isFinite(v), w + ' (finite number)')
+			}
+			if (w === 'float32') {
+				// Values are rounded to the nearest float32, like Go's largest float32
+				// 3.4028235e+38 from its JSON package. Too large values become Infinity.
+				this.ensure(path, v, Number.isFinite(Math.fround(v)), 'float32 (in range of float32)')
+			}
 			return v
 		case 'int64s':
 		case 'uint64s':
-			ensure(t === 'number' && Number.isInteger(v) || t === 'string', 'integer fitting in float without precision loss, or string')
+			if (this.typeOpts.bigint) {
+				return this.bigint(path, v, w, true)
+			}
+			this.ensure(path, v, t === 'number' && Number.isInteger(v) || t === 'string', 'integer fitting in float without precision loss, or string')
 			return '' + v
 		case 'string':
-			ensure(t === 'string', 'string')
+			this.ensure(path, v, t === 'string', 'string')
 			return v
 		case 'timestamp':
-			if (this.toJS) {
-				ensure(t === 'string', 'string, with timestamp')
+			const mode = this.typeOpts.timestamp
+			// Temporal through globalThis, it is only required for the "temporal" mode.
+			const Temporal = mode === 'temporal' ? (globalThis as any).Temporal : undefined
+			if (!this.toJS && mode !== 'string' && t === 'string' && this.typeOpts.splitTypes) {
+				// With split types, parameters can have timestamps as string, as in the In types.
+				if (mode === 'date') {
+					this.ensure(path, v, !isNaN(new Date(v).getTime()), 'string, with timestamp')
+					return v
+				}
+				return this.ensure(path, v, _sherpaRFC3339.test(v), 'string, with RFC3339 timestamp')
+			}
+			if (this.toJS && mode === 'date') {
+				// Any string that is a valid date, as before the timestamp modes.
+				this.ensure(path, v, t === 'string', 'string, with timestamp')
+			} else if (this.toJS || mode === 'string') {
+				// Strings are kept or parsed as is, so must be exact.
+				this.ensure(path, v, t === 'string' && _sherpaRFC3339.test(v), 'string, with RFC3339 timestamp')
+			}
+			if (mode === 'string') {
+				return v
+			} else if (mode === 'temporal') {
+				if (this.toJS) {
+					try {
+						return Temporal.Instant.from(v)
+					} catch (err) {
+						this.error(path, v, 'invalid timestamp ' + v, 'valid timestamp')
+					}
+				}
+				this.ensure(path, v, v instanceof Temporal.Instant, 'Temporal.Instant')
+				return v.toString()
+			} else if (this.toJS) {
 				const d = new Date(v)
 				if (d instanceof Date && !isNaN(d.getTime())) {
 					return d
 				}
-				error('invalid date ' + v)
+				this.error(path, v, 'invalid date ' + v, 'valid date')
 			} else {
-				ensure(t === 'object' && v !== null, 'non-null object')
-				ensure(v.__proto__ === Date.prototype, 'Date')
+				this.ensure(path, v, v instanceof Date && !isNaN(v.getTime()), 'valid Date')
 				return v.toISOString()
 			}
 		}
 
 		// We're left with named types.
+		VERIFYHOOKS
+		return this.named(path, v, w)
+	}
+
+	named(path: string, v: any, w: string): any {
 		const nt = this.types[w]
 		if (!nt) {
-			error('unknown type ' + w)
+			this.error(path, v, 'unknown type ' + w, 'known type')
 		}
 		if (v === null) {
-			error('bad value ' + v + ' for named type ' + w)
+			this.error(path, v, 'bad value ' + v + ' for named type ' + w, w)
 		}
 
 		if (structTypes[nt.Name]) {
 			const t = nt as Struct
 			if (typeof v !== 'object') {
-				error('bad value ' + v + ' for struct ' + w)
+				this.error(path, v, 'bad value ' + v + ' for struct ' + w, 'struct ' + w)
 			}
 
+			const cs = _sherpaStructChecks(t)
 			const r: any = {}
-			for (const f of t.Fields) {
-				r[f.Name] = this.verify(path + '.' + f.Name, v[f.Name], f.Typewords)
+			for (const [name, check] of cs.fields) {
+				r[name] = this.sub(path + '.' + name, v[name], check)
 			}
 			// If going to JSON also verify no unknown fields are present.
 			if (!this.allowUnknownKeys) {
-				const known: { [key: string]: boolean } = {}
-				for (const f of t.Fields) {
-					known[f.Name] = true
+				const known = cs.known
+				const unknown = Object.keys(v).filter((k) => !known[k])
+				if (unknown.length > 0) {
+					this.error(path, v, 'unknown key ' + unknown.join(', ') + ' for struct ' + w, 'known keys for struct ' + w, unknown.join(', '))
 				}
-				Object.keys(v).forEach((k) => {
-					if (!known[k]) {
-						error('unknown key ' + k + ' for struct ' + w)
-					}
-				})
 			}
 			return r
 		} else if (stringsTypes[nt.Name]) {
 			const t = nt as Strings
 			if (typeof v !== 'string') {
-				error('mistyped value ' + v + ' for named strings ' + t.Name)
+				this.error(path, v, 'mistyped value ' + v + ' for named strings ' + t.Name, 'string')
 			}
 			if (!t.Values || t.Values.length === 0) {
 				return v
@@ -218,11 +432,11 @@ class verifier {
 					return v
 				}
 			}
-			error('unknown value ' + v + ' for named strings ' + t.Name)
+			this.error(path, v, 'unknown value ' + v + ' for named strings ' + t.Name, 'value of named strings ' + t.Name)
 		} else if (intsTypes[nt.Name]) {
 			const t = nt as Ints
 			if (typeof v !== 'number' || !Number.isInteger(v)) {
-				error('mistyped value ' + v + ' for named ints ' + t.Name)
+				this.error(path, v, 'mistyped value ' + v + ' for named ints ' + t.Name, 'integer')
 			}
 			if (!t.Values || t.Values.length === 0) {
 				return v
@@ -232,15 +446,65 @@ class verifier {
 					return v
 				}
 			}
-			error('unknown value ' + v + ' for named ints ' + t.Name)
+			this.error(path, v, 'unknown value ' + v + ' for named ints ' + t.Name, 'value of named ints ' + t.Name)
 		} else {
 			throw new Error('unexpected named type ' + nt)
 		}
 	}
 }
 
+// verifyParams returns the parameters for a call of function name, verified and
+// converted for JSON. An exception is thrown for invalid parameters.
+const verifyParams = (name: string, paramTypes: string[][], types: TypenameMap, options: ClientOptions, params: any[]): any[] => {
+	const checks = _sherpaChecks(name, paramTypes, false)
+	return params.map((v: any, index: number) => verifyCheck('params[' + index + ']', v, checks[index], false, false, types, options))
+}
 
-export interface ClientOptions {
+// verifyResult returns the result of a call of function name, verified and
+// converted for JS. An exception is thrown for an invalid result.
+const verifyResult = (name: string, returnTypes: string[][], types: TypenameMap, options: ClientOptions, result: any): any => {
+	const checks = _sherpaChecks(name, returnTypes, true)
+	if (returnTypes.length === 0) {
+		if (result) {
+			throw new Error('function ' + name + ' returned a value while prototype says it returns "void"')
+		}
+		return result
+	} else if (returnTypes.length === 1) {
+		return verifyCheck('result', result, checks[0], true, true, types, options)
+	}
+	if (result.length != returnTypes.length) {
+		throw new Error('wrong number of values returned by ' + name + ', saw ' + result.length + ' != expected ' + returnTypes.length)
+	}
+	return result.map((v: any, index: number) => verifyCheck('result[' + index + ']', v, checks[index], true, true, types, options))
+}
+
+// resultIssues verifies an invalid result again, gathering all issues, for
+// finding all differences between server and client at once.
+const resultIssues = (name: string, returnTypes: string[][], types: TypenameMap, options: ClientOptions, result: any): VerifyIssue[] => {
+	const checks = _sherpaChecks(name, returnTypes, true)
+	let issues: VerifyIssue[] = []
+	if (returnTypes.length === 1) {
+		issues = verifyAllCheck('result', result, checks[0], true, true, types, options).issues
+	} else if (Array.isArray(result) && result.length === returnTypes.length) {
+		result.forEach((v: any, index: number) => {
+			issues.push(...verifyAllCheck('result[' + index + ']', v, checks[index], true, true, types, options).issues)
+		})
+	}
+	return issues
+}
+`
+
+// noVerifierTS replaces verifierTS without runtime type information.
+const noVerifierTS = `// Generated without runtime type information, parameters and results are not verified.
+const verifyParams = (name: string, paramTypes: string[][], types: TypenameMap, options: ClientOptions, params: any[]): any[] => params
+const verifyResult = (name: string, returnTypes: string[][], types: TypenameMap, options: ClientOptions, result: any): any => result
+const resultIssues = (name: string, returnTypes: string[][], types: TypenameMap, options: ClientOptions, result: any): any[] => []
+`
+
+// clientTS calls functions of the API. PARSEJSON and STRINGIFYJSON are replaced
+// with the functions for parsing responses and writing requests. ALLTYPES is
+// replaced with the table with all types, or an empty table without it.
+const clientTS = `export interface ClientOptions {
 	baseURL?: string
 	aborter?: {abort?: () => void}
 	timeoutMsec?: number
@@ -253,17 +517,36 @@ export interface ClientOptions {
 	login?: (reason: string) => Promise<string>
 }
 
+// ClientConfig is the configuration for calling standalone functions. A Client
+// can be used as ClientConfig. Without authState, tokens from logins, see
+// ClientOptions.login, are not kept between calls.
+export interface ClientConfig {
+	authState?: AuthState
+	options?: ClientOptions
+}
+
 export interface AuthState {
 	token?: string // For csrf request header.
 	loginPromise?: Promise<void> // To let multiple API calls wait for a single login attempt, not each opening a login popup.
 }
 
-const _sherpaCall = async (baseURL: string, authState: AuthState, options: ClientOptions, paramTypes: string[][], returnTypes: string[][], name: string, params: any[]): Promise<any> => {
-	if (!options.skipParamCheck) {
+// _sherpaCallConfig calls a function for a standalone function, with the default
+// options for fields not set in config.
+const _sherpaCallConfig = async (config: ClientConfig, paramTypes: string[][] | null, returnTypes: string[][] | null, fnTypes: TypenameMap | null, name: string, params: any[]): Promise<any> => {
+	const options: ClientOptions = { ...defaultOptions, ...config.options }
+	return await _sherpaCall(options.baseURL || defaultBaseURL, config.authState || {}, options, paramTypes, returnTypes, fnTypes, name, params)
+}
+
+// Without runtime type information, paramTypes and returnTypes are null and
+// parameters and results are not verified. With lazy runtime types, fnTypes
+// are the named types referenced by the function, otherwise it is null.
+const _sherpaCall = async (baseURL: string, authState: AuthState, options: ClientOptions, paramTypes: string[][] | null, returnTypes: string[][] | null, fnTypes: TypenameMap | null, name: string, params: any[]): Promise<any> => {
+	const namedTypes = fnTypes || ALLTYPES
+	if (!options.skipParamCheck && paramTypes) {
 		if (params.length !== paramTypes.length) {
 			return Promise.reject({ message: 'wrong number of parameters in sherpa call, saw ' + params.length + ' != expected ' + paramTypes.length })
 		}
-		params = params.map((v: any, index: number) => verifyArg('params[' + index + ']', v, paramTypes[index], false, false, types, options))
+		params = verifyParams(name, paramTypes, namedTypes, options, params)
 	}
 	const simulate = async (json: string) => {
 		const config = JSON.parse(json || 'null') || {}
@@ -305,7 +588,7 @@ const _sherpaCall = async (baseURL: string, authState: AuthState, options: Clien
 			resolve1 = () => { }
 			reject1 = () => { }
 		}
-		let reject1 = (v: { code: string, message: string }) => {
+		let reject1 = (v: { code: string, message: string, issues?: any[] }) => {
 			if ((v.code === 'user:noAuth' || v.code === 'user:badAuth')  && options.login) {
 				const login = options.login
 				if (!authState.loginPromise) {
@@ -361,7 +644,7 @@ const _sherpaCall = async (baseURL: string, authState: AuthState, options: Clien
 
 			let resp: any
 			try {
-				resp = JSON.parse(req.responseText)
+				resp = PARSEJSON(req.responseText)
 			} catch (err) {
 				reject1({ code: 'sherpa:badResponse', message: 'bad JSON from server' })
 				return
@@ -375,30 +658,19 @@ const _sherpaCall = async (baseURL: string, authState: AuthState, options: Clien
 				return
 			}
 
-			if (options.skipReturnCheck) {
+			if (options.skipReturnCheck || !returnTypes) {
 				resolve1(resp.result)
 				return
 			}
 			let result = resp.result
 			try {
-				if (returnTypes.length === 0) {
-					if (result) {
-						throw new Error('function ' + name + ' returned a value while prototype says it returns "void"')
-					}
-				} else if (returnTypes.length === 1) {
-					result = verifyArg('result', result, returnTypes[0], true, true, types, options)
-				} else {
-					if (result.length != returnTypes.length) {
-						throw new Error('wrong number of values returned by ' + name + ', saw ' + result.length + ' != expected ' + returnTypes.length)
-					}
-					result = result.map((v: any, index: number) => verifyArg('result[' + index + ']', v, returnTypes[index], true, true, types, options))
-				}
+				result = verifyResult(name, returnTypes, namedTypes, options, result)
 			} catch (err) {
 				let errmsg = 'bad types'
 				if (err instanceof Error) {
 					errmsg = err.message
 				}
-				reject1({ code: 'sherpa:badTypes', message: errmsg })
+				reject1({ code: 'sherpa:badTypes', message: errmsg, issues: resultIssues(name, returnTypes, namedTypes, options, resp.result) })
 			}
 			resolve1(result)
 		}
@@ -410,7 +682,7 @@ const _sherpaCall = async (baseURL: string, authState: AuthState, options: Clien
 		}
 		req.setRequestHeader('Content-Type', 'application/json')
 		try {
-			req.send(JSON.stringify({ params: params }))
+			req.send(STRINGIFYJSON({ params: params }))
 		} catch (err) {
 			reject1({ code: 'sherpa:badData', message: 'cannot marshal to JSON' })
 		}
@@ -418,3 +690,372 @@ const _sherpaCall = async (baseURL: string, authState: AuthState, options: Clien
 	return await new Promise(fn)
 }
 `
+
+const deprecatedTS = `const _sherpaDeprecatedWarned: {[fn: string]: boolean} = {}
+
+// _sherpaWarnDeprecated logs a warning the first time deprecated function fn is
+// called, with a stack trace for finding the caller.
+const _sherpaWarnDeprecated = (fn: string, msg: string) => {
+	if (_sherpaDeprecatedWarned[fn]) {
+		return
+	}
+	_sherpaDeprecatedWarned[fn] = true
+	console.warn('sherpa: function ' + fn + ' is deprecated' + (msg ? ': ' + msg : ''), new Error().stack)
+}
+`
+
+// cloneEqualTS has the generic clone and equal functions, for
+// Options.CloneEqual. CLONEHOOKS and EQUALHOOKS are replaced with cloneHooksTS
+// and equalHooksTS with type mappings.
+const cloneEqualTS = `// clone returns a deep copy of v, a value of named type name, e.g. for editing a
+// copy of a value returned by a function. Dates are copied. Values of types with
+// type hooks, and Temporal.Instants, are assumed to be immutable and are not
+// copied.
+export const clone = <T>(name: string, v: T): T => _sherpaCloneTypewords([name], v)
+
+// equal returns whether a and b, values of named type name, are structurally
+// equal, e.g. for checking whether a copy in a form has been changed. Dates and
+// Temporal.Instants are equal if they are the same instant, keys of maps and
+// structs are compared regardless of order, and a missing optional field equals
+// null. Values of types with type hooks are compared with their "equals" method
+// if they have one.
+export const equal = <T>(name: string, a: T, b: T): boolean => _sherpaEqualTypewords([name], a, b)
+
+const _sherpaCloneTypewords = (typewords: string[], v: any): any => {
+	if (v === null || v === undefined) {
+		return v
+	}
+	const w = typewords[0]
+	const rest = typewords.slice(1)
+	switch (w) {
+	case 'nullable':
+		return _sherpaCloneTypewords(rest, v)
+	case '[]':
+		return v.map((e: any) => _sherpaCloneTypewords(rest, e))
+	case '{}':
+		const r: any = {}
+		for (const k of Object.keys(v)) {
+			r[k] = _sherpaCloneTypewords(rest, v[k])
+		}
+		return r
+	case 'timestamp':
+		return v instanceof Date ? new Date(v.getTime()) : v
+	case 'any':
+		return _sherpaCloneAny(v)
+	}
+	CLONEHOOKS
+	const t = types[w] as Struct
+	if (!t || !t.Fields) {
+		return v
+	}
+	const r: any = { ...v }
+	for (const f of t.Fields) {
+		if (f.Name in v) {
+			r[f.Name] = _sherpaCloneTypewords(f.Typewords, v[f.Name])
+		}
+	}
+	return r
+}
+
+const _sherpaCloneAny = (v: any): any => {
+	if (v instanceof Date) {
+		return new Date(v.getTime())
+	} else if (Array.isArray(v)) {
+		return v.map(_sherpaCloneAny)
+	} else if (v && typeof v === 'object') {
+		const r: any = {}
+		for (const k of Object.keys(v)) {
+			r[k] = _sherpaCloneAny(v[k])
+		}
+		return r
+	}
+	return v
+}
+
+const _sherpaEqualTypewords = (typewords: string[], a: any, b: any): boolean => {
+	if (a === b) {
+		return true
+	}
+	if (a === null || a === undefined || b === null || b === undefined) {
+		return (a === null || a === undefined) && (b === null || b === undefined)
+	}
+	const w = typewords[0]
+	const rest = typewords.slice(1)
+	switch (w) {
+	case 'nullable':
+		return _sherpaEqualTypewords(rest, a, b)
+	case '[]':
+		return a.length === b.length && a.every((e: any, i: number) => _sherpaEqualTypewords(rest, e, b[i]))
+	case '{}':
+		const ka = Object.keys(a)
+		return ka.length === Object.keys(b).length && ka.every(k => k in b && _sherpaEqualTypewords(rest, a[k], b[k]))
+	case 'timestamp':
+		if (a instanceof Date && b instanceof Date) {
+			return a.getTime() === b.getTime()
+		}
+		return typeof a.equals === 'function' && a.equals(b)
+	case 'any':
+		return _sherpaEqualAny(a, b)
+	}
+	EQUALHOOKS
+	const t = types[w] as Struct
+	if (!t || !t.Fields) {
+		return false
+	}
+	return t.Fields.every(f => _sherpaEqualTypewords(f.Typewords, a[f.Name], b[f.Name]))
+}
+
+const _sherpaEqualAny = (a: any, b: any): boolean => {
+	if (a === b) {
+		return true
+	} else if (a instanceof Date && b instanceof Date) {
+		return a.getTime() === b.getTime()
+	} else if (Array.isArray(a) && Array.isArray(b)) {
+		return a.length === b.length && a.every((e, i) => _sherpaEqualAny(e, b[i]))
+	} else if (a && b && typeof a === 'object' && typeof b === 'object' && !Array.isArray(a) && !Array.isArray(b)) {
+		const ka = Object.keys(a)
+		return ka.length === Object.keys(b).length && ka.every(k => k in b && _sherpaEqualAny(a[k], b[k]))
+	}
+	return false
+}
+`
+
+// bigintTS has the JSON functions for BigInt, used with Options.BigInt.
+const bigintTS = `// _sherpaParseJSONBigInt parses JSON like JSON.parse, but returns integers that cannot
+// be represented exactly as number as BigInt.
+const _sherpaParseJSONBigInt = (s: string): any => {
+	let i = 0
+	const fail = (): never => {
+		throw new Error('bad JSON at offset ' + i)
+	}
+	const space = () => {
+		while (i < s.length && ' \t\r\n'.indexOf(s[i]) >= 0) {
+			i++
+		}
+	}
+	const match = (re: RegExp): string => {
+		re.lastIndex = i
+		const m = re.exec(s)
+		if (!m) {
+			return fail()
+		}
+		i += m[0].length
+		return m[0]
+	}
+	const str = (): string => JSON.parse(match(/"(?:[^"\\\u0000-\u001f]|\\(?:["\\/bfnrt]|u[0-9a-fA-F]{4}))*"/y))
+	const value = (): any => {
+		space()
+		if (s[i] === '{') {
+			i++
+			const r: any = {}
+			space()
+			if (s[i] === '}') {
+				i++
+				return r
+			}
+			for (;;) {
+				space()
+				const k = str()
+				space()
+				if (s[i++] !== ':') {
+					fail()
+				}
+				// Keep "__proto__" a regular key, like JSON.parse does.
+				Object.defineProperty(r, k, {value: value(), enumerable: true, writable: true, configurable: true})
+				space()
+				const c = s[i++]
+				if (c === '}') {
+					return r
+				} else if (c !== ',') {
+					fail()
+				}
+			}
+		} else if (s[i] === '[') {
+			i++
+			const r: any[] = []
+			space()
+			if (s[i] === ']') {
+				i++
+				return r
+			}
+			for (;;) {
+				r.push(value())
+				space()
+				const c = s[i++]
+				if (c === ']') {
+					return r
+				} else if (c !== ',') {
+					fail()
+				}
+			}
+		} else if (s[i] === '"') {
+			return str()
+		}
+		const w = match(/true|false|null|-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?/y)
+		if (w === 'true' || w === 'false' || w === 'null') {
+			return JSON.parse(w)
+		}
+		const n = Number(w)
+		if (/^-?[0-9]+$/.test(w) && !Number.isSafeInteger(n)) {
+			return BigInt(w)
+		}
+		return n
+	}
+	const r = value()
+	space()
+	if (i !== s.length) {
+		fail()
+	}
+	return r
+}
+
+// _sherpaStringifyJSONBigInt returns JSON like JSON.stringify, but writes BigInts as
+// JSON numbers.
+const _sherpaStringifyJSONBigInt = (v: any): string => {
+	if (typeof v === 'bigint') {
+		return v.toString()
+	} else if (v === null || typeof v !== 'object') {
+		return JSON.stringify(v)
+	} else if (typeof v.toJSON === 'function') {
+		return _sherpaStringifyJSONBigInt(v.toJSON())
+	} else if (Array.isArray(v)) {
+		return '[' + v.map((e: any) => e === undefined ? 'null' : _sherpaStringifyJSONBigInt(e)).join(',') + ']'
+	}
+	const l: string[] = []
+	for (const k of Object.keys(v)) {
+		if (v[k] !== undefined) {
+			l.push(JSON.stringify(k) + ':' + _sherpaStringifyJSONBigInt(v[k]))
+		}
+	}
+	return '{' + l.join(',') + '}'
+}
+`
+
+// typeHooksTS is the type of the hooks for named types with a TypeMapping, with
+// parse or serialize functions.
+const typeHooksTS = `// TypeHooks convert values of named types mapped to other TypeScript types.
+// Parse is called with a verified JSON value. Serialize is called before
+// verifying the returned JSON value.
+export interface TypeHooks {
+	parse?: (v: any) => any
+	serialize?: (v: any) => any
+}
+`
+
+// verifyHooksTS calls the hooks of named types when verifying values.
+const verifyHooksTS = `		// Types mapped to other TypeScript types can have hooks for converting from and
+		// to their JSON representation.
+		const hooks = typeHooks[w]
+		if (hooks) {
+			const hook = (fn: (v: any) => any, what: string): any => {
+				try {
+					return fn(v)
+				} catch (err) {
+					this.error(path, v, what + ' ' + w + ': ' + ((err as any).message || err), w)
+				}
+			}
+			if (hooks.serialize && !this.toJS && v !== null && v !== undefined) {
+				v = hook(hooks.serialize, 'serializing')
+			}
+			v = this.named(path, v, w)
+			if (hooks.parse && this.toJS) {
+				v = hook(hooks.parse, 'parsing')
+			}
+			return v
+		}
+`
+
+// cloneHooksTS keeps values of named types with hooks as is when cloning.
+const cloneHooksTS = `	if (typeHooks[w]) {
+		return v
+	}
+`
+
+// equalHooksTS compares values of named types with hooks with their "equals"
+// method.
+const equalHooksTS = `	if (typeHooks[w]) {
+		return typeof a.equals === 'function' && a.equals(b)
+	}
+`
+
+// guardTS is used by the type guards, for Options.Guards.
+const guardTS = `// _sherpaGuard returns whether v is a valid value for named type name, as it
+// would be passed to a function, e.g. with a Date for a timestamp. JSON values,
+// e.g. with a string for a timestamp, are not valid, use parser or safeParser
+// for those. Unknown keys in structs are allowed. Unlike parse, v is not
+// modified and no exception is thrown. Guards check for type Foo, not for FooIn
+// with split types.
+const _sherpaGuard = (name: string, v: any, types: TypenameMap): boolean => {
+	try {
+		new verifier(types, false, true, defaultOptions, { ..._sherpaTypeOptions, splitTypes: false }).verify(name, v, [name])
+		return true
+	} catch (err) {
+		return false
+	}
+}
+`
+
+// safeParserTS is used by safeParser, for Options.SafeParser.
+const safeParserTS = `export type SafeParseResult<T> = { ok: true, value: T } | { ok: false, issues: VerifyIssue[] }
+
+// _sherpaSafeParse is like parse, but returns all issues instead of throwing an exception.
+const _sherpaSafeParse = <T>(name: string, v: any, types: TypenameMap): SafeParseResult<T> => {
+	const r = verifyAll(name, v, [name], true, false, types, defaultOptions)
+	if (r.issues.length > 0) {
+		return { ok: false, issues: r.issues }
+	}
+	return { ok: true, value: r.value as T }
+}
+`
+
+// compiledChecksTS has the checks used in the compiled checks, for
+// Options.CompiledChecks. They are followed by the generated compiled checks.
+const compiledChecksTS = `// _sherpaCompiledSignature has checks for the parameters and results of a function.
+interface _sherpaCompiledSignature {
+	params: _sherpaCheck[]
+	returns: _sherpaCheck[]
+}
+
+const _sherpaCheckBase = (w: string): _sherpaCheck => {
+	// Fast paths for valid values of common types, others are verified by the verifier.
+	switch (w) {
+	case 'string':
+		return (vf, path, v) => typeof v === 'string' ? v : vf.base(path, v, w)
+	case 'bool':
+		return (vf, path, v) => typeof v === 'boolean' ? v : vf.base(path, v, w)
+	case 'int8':
+	case 'uint8':
+	case 'int16':
+	case 'uint16':
+	case 'int32':
+	case 'uint32':
+		const [min, max] = _sherpaIntRanges[w]
+		return (vf, path, v) => typeof v === 'number' && Number.isInteger(v) && v >= min && v < max ? v : vf.base(path, v, w)
+	}
+	return (vf, path, v) => vf.base(path, v, w)
+}
+const _sherpaCheckNullable = (check: _sherpaCheck): _sherpaCheck => (vf, path, v) => vf.nullable(path, v, check)
+const _sherpaCheckArray = (check: _sherpaCheck): _sherpaCheck => (vf, path, v) => vf.array(path, v, check)
+const _sherpaCheckMap = (check: _sherpaCheck): _sherpaCheck => (vf, path, v) => vf.map(path, v, check)
+const _sherpaCompileStruct = (fields: { [name: string]: _sherpaCheck }): _sherpaCompiledStruct => {
+	const known: { [key: string]: boolean } = {}
+	for (const k in fields) {
+		known[k] = true
+	}
+	return { fields: Object.keys(fields).map((k): [string, _sherpaCheck] => [k, fields[k]]), known: known }
+}
+`
+
+// compiledStructTS replaces COMPILEDSTRUCT in verifierTS with compiled checks.
+const compiledStructTS = `	const cs = _sherpaCompiledStructs[t.Name]
+	if (cs) {
+		return cs
+	}
+`
+
+// compiledSignatureTS replaces COMPILEDSIGNATURE in verifierTS with compiled checks.
+const compiledSignatureTS = `	const sig = _sherpaCompiledSignatures[name]
+	if (sig) {
+		return returns ? sig.returns : sig.params
+	}
+`
@@ -0,0 +1,500 @@
+package sherpats
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runTS generates code for testdata/api.json with opts as api.ts, and runs
+// script, a TypeScript module that can import from "./api.ts", with node. The
+// output of the script is returned. The test is skipped if node is not found,
+// or does not support running TypeScript.
+func runTS(t *testing.T, opts Options, script string) string {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	args := []string{"--experimental-transform-types", "--no-warnings"}
+	if err := exec.Command(node, append(args, "-e", "")...).Run(); err != nil {
+		t.Skip("node cannot run typescript")
+	}
+
+	f, err := os.Open("testdata/api.json")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var b bytes.Buffer
+	if err := Generate(f, &b, "http://localhost/api/", opts); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "api.ts"), b.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.mts"), []byte(script), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cmd := exec.Command(node, append(args, "test.mts")...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running script: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+// checkOutput compares output of runTS with exp, ignoring leading and trailing
+// white space.
+func checkOutput(t *testing.T, out, exp string) {
+	t.Helper()
+	out = strings.TrimSpace(out)
+	exp = strings.TrimSpace(exp)
+	if out != exp {
+		t.Fatalf("got output:\n%s\n\nexpected:\n%s", out, exp)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	// Boundaries of integers and floats, for values coming from JSON. Go writes
+	// the largest int64 and uint64 as JSON numbers that JavaScript rounds up to 2^63
+	// and 2^64.
+	const script = `import {verifyArg, types} from './api.ts'
+const check = (w: string, v: any) => {
+	try {
+		verifyArg('v', v, [w], true, false, types, {})
+		console.log(w, v, 'ok')
+	} catch (err) {
+		console.log(w, v, 'error')
+	}
+}
+check('int8', 127)
+check('int8', 128)
+check('int8', -128)
+check('int8', -129)
+check('uint8', 255)
+check('uint8', 256)
+check('uint8', -1)
+check('int32', 2147483647)
+check('int32', 2147483648)
+check('uint32', 4294967295)
+check('uint32', 4294967296)
+check('int64', JSON.parse('9223372036854775807'))
+check('int64', 2**63 + 2048)
+check('int64', JSON.parse('-9223372036854775808'))
+check('int64', -(2**63) - 2048)
+check('uint64', JSON.parse('18446744073709551615'))
+check('uint64', 2**64 + 4096)
+check('uint64', -1)
+check('float32', JSON.parse('3.4028235e+38'))
+check('float32', 3.5e38)
+check('float32', -3.5e38)
+check('float32', 1e-50)
+check('float64', 1.7976931348623157e308)
+check('float64', Infinity)
+check('float64', NaN)
+`
+	out := runTS(t, Options{}, script)
+	checkOutput(t, out, `
+int8 127 ok
+int8 128 error
+int8 -128 ok
+int8 -129 error
+uint8 255 ok
+uint8 256 error
+uint8 -1 error
+int32 2147483647 ok
+int32 2147483648 error
+uint32 4294967295 ok
+uint32 4294967296 error
+int64 9223372036854776000 ok
+int64 9223372036854778000 error
+int64 -9223372036854776000 ok
+int64 -9223372036854778000 error
+uint64 18446744073709552000 ok
+uint64 18446744073709556000 error
+uint64 -1 error
+float32 3.4028235e+38 ok
+float32 3.5e+38 error
+float32 -3.5e+38 error
+float32 1e-50 ok
+float64 1.7976931348623157e+308 ok
+float64 Infinity error
+float64 NaN error
+`)
+
+	// With BigInt, int64 and uint64 are checked exactly.
+	const bigintScript = `import {verifyArg, types} from './api.ts'
+const check = (w: string, v: any) => {
+	try {
+		verifyArg('v', v, [w], true, false, types, {})
+		console.log(w, v, 'ok')
+	} catch (err) {
+		console.log(w, v, 'error')
+	}
+}
+check('int64', 9223372036854775807n)
+check('int64', 9223372036854775808n)
+check('int64', -9223372036854775808n)
+check('int64', -9223372036854775809n)
+check('uint64', 18446744073709551615n)
+check('uint64', 18446744073709551616n)
+check('uint64', -1n)
+check('int64s', '9223372036854775807')
+check('int64s', '9223372036854775808')
+`
+	out = runTS(t, Options{BigInt: true}, bigintScript)
+	checkOutput(t, out, `
+int64 9223372036854775807n ok
+int64 9223372036854775808n error
+int64 -9223372036854775808n ok
+int64 -9223372036854775809n error
+uint64 18446744073709551615n ok
+uint64 18446744073709551616n error
+uint64 -1n error
+int64s 9223372036854775807 ok
+int64s 9223372036854775808 error
+`)
+}
+
+func TestTimestampVerify(t *testing.T) {
+	const script = `import {verifyArg, types} from './api.ts'
+class Instant {
+	s: string
+	constructor(s: string) { this.s = s }
+	static from(s: string) { return new Instant(s) }
+	toString() { return this.s }
+}
+(globalThis as any).Temporal = {Instant}
+const check = (toJS: boolean, v: any) => {
+	try {
+		const r = verifyArg('v', v, ['timestamp'], toJS, false, types, {})
+		console.log(JSON.stringify(v), r instanceof Date ? 'Date ' + r.toISOString() : r instanceof Instant ? 'Instant ' + r : JSON.stringify(r))
+	} catch (err) {
+		console.log(JSON.stringify(v), 'error')
+	}
+}
+check(true, '2024-01-02T03:04:05.123456789Z')
+check(true, '2024-01-02T03:04:05+02:00')
+check(true, '2024-01-02 03:04:05Z')
+check(true, 'bogus')
+check(true, 1)
+`
+	// Date mode accepts any string that is a valid date, like before the timestamp modes.
+	checkOutput(t, runTS(t, Options{}, script), `
+"2024-01-02T03:04:05.123456789Z" Date 2024-01-02T03:04:05.123Z
+"2024-01-02T03:04:05+02:00" Date 2024-01-02T01:04:05.000Z
+"2024-01-02 03:04:05Z" Date 2024-01-02T03:04:05.000Z
+"bogus" error
+1 error
+`)
+	// String and temporal modes keep the timestamp exactly, and require RFC3339.
+	checkOutput(t, runTS(t, Options{Timestamp: "string"}, script), `
+"2024-01-02T03:04:05.123456789Z" "2024-01-02T03:04:05.123456789Z"
+"2024-01-02T03:04:05+02:00" "2024-01-02T03:04:05+02:00"
+"2024-01-02 03:04:05Z" error
+"bogus" error
+1 error
+`)
+	checkOutput(t, runTS(t, Options{Timestamp: "temporal"}, script), `
+"2024-01-02T03:04:05.123456789Z" Instant 2024-01-02T03:04:05.123456789Z
+"2024-01-02T03:04:05+02:00" Instant 2024-01-02T03:04:05+02:00
+"2024-01-02 03:04:05Z" error
+"bogus" error
+1 error
+`)
+}
+
+func TestEnumHelpersFromString(t *testing.T) {
+	const script = `import {kindFromString, levelFromString} from './api.ts'
+console.log(kindFromString('admin'), kindFromString('Admin'), levelFromString('2'), levelFromString('3'))
+`
+	checkOutput(t, runTS(t, Options{EnumHelpers: true}, script), "admin undefined 2 undefined")
+}
+
+func TestGuardValues(t *testing.T) {
+	// Guards check in-memory values, not JSON values: with the default timestamp
+	// mode, a timestamp is a Date in memory, but a string in JSON.
+	const script = `import {parser, isUser} from './api.ts'
+const json = {ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: [], Attrs: {}, Kind: 'admin', Friend: null, Data: [], Other: {type: 't', 'my-field': null}}
+const u = parser.User(structuredClone(json))
+console.log(isUser(u), isUser(json), isUser({...u, Extra: true}), isUser({...u, Kind: 'bogus'}))
+`
+	checkOutput(t, runTS(t, Options{Guards: true}, script), "true false true false")
+	// With timestamps as string, in-memory and JSON values are the same.
+	checkOutput(t, runTS(t, Options{Guards: true, Timestamp: "string"}, script), "true true true false")
+}
+
+func TestSafeParserIssues(t *testing.T) {
+	const script = `import {safeParser} from './api.ts'
+const json = {ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: [], Attrs: {}, Kind: 'admin', Friend: null, Data: [], Other: {type: 't', 'my-field': null}}
+const r = safeParser.User(json)
+console.log(r.ok, r.ok && r.value.Created instanceof Date)
+const bad = safeParser.User({...json, ID: 'x', Kind: 'bogus', Tags: [1, 'a', 2]})
+if (!bad.ok) {
+	for (const issue of bad.issues) {
+		console.log(issue.path)
+	}
+}
+`
+	checkOutput(t, runTS(t, Options{SafeParser: true}, script), `
+true true
+User.ID
+User.Tags[0]
+User.Tags[2]
+User.Kind
+`)
+}
+
+func TestCompiledChecks(t *testing.T) {
+	// Compiled checks must behave the same as interpreting typewords, for valid
+	// and invalid values, when parsing, when collecting issues and for parameters
+	// and results of function calls.
+	const script = `import {Client, parser, verifyArg, verifyAll, types} from './api.ts'
+let response = ''
+class XMLHttpRequest {
+	status = 200
+	responseText = ''
+	onload = () => {}
+	open() {}
+	setRequestHeader() {}
+	send(body: string) {
+		console.log('request', body)
+		this.responseText = response
+		this.onload()
+	}
+}
+(globalThis as any).window = {XMLHttpRequest}
+const show = (v: any) => JSON.stringify(v, (k, v) => v === undefined ? 'undefined' : v)
+const user = {ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: ['a'], Attrs: {a: null, b: 'b'}, Kind: 'admin', Friend: null, Data: [1, 2], Other: {type: 't', 'my-field': {type: 'u', 'my-field': null}}}
+const values: any[] = [
+	user,
+	{...user, Friend: {...user, Name: 'friend'}},
+	{...user, Extra: true},
+	{...user, ID: 'x', Kind: 'bogus', Tags: [1, 'a', 2], Data: null},
+	{...user, Other: {type: 1, 'my-field': {}}},
+	{...user, Created: 'bogus', Attrs: {a: 1}},
+	null,
+	[],
+]
+for (const v of values) {
+	try {
+		console.log('parse', show(parser.User(structuredClone(v))))
+	} catch (err) {
+		console.log('parse', '' + err)
+	}
+	try {
+		console.log('verify', show(verifyArg('u', [v && v.Created ? {...v, Created: new Date(v.Created)} : v], ['[]', 'User'], false, true, types, {})))
+	} catch (err) {
+		console.log('verify', '' + err)
+	}
+	console.log('all', show(verifyAll('u', structuredClone(v), ['nullable', 'User'], true, false, types, {})))
+}
+const client = new Client()
+const call = async (result: any, f: () => Promise<any>) => {
+	response = JSON.stringify({result})
+	try {
+		console.log('result', show(await f()))
+	} catch (err) {
+		console.log('error', err instanceof Error ? '' + err : show(err))
+	}
+}
+const u = parser.User(structuredClone(user))
+await call(user, () => client.Echo(u, null))
+await call({...user, ID: 'x'}, () => client.Echo(u, 'x'))
+await call(user, () => client.Echo({...u, Kind: 'bogus' as any}, null))
+await call(['x', 'y'], () => client.Login('x', 1))
+await call([user, 1], () => client.Login('x', 1.5))
+await call(null, () => client.delete(['a'], 'regular' as any))
+await call([[1, 2], 2], () => client.Stats({a: 1}, new Date(0), {any: true}))
+await call([[1, 'x'], 3], () => client.Stats({a: 1}, new Date(0), null))
+`
+	interpreted := runTS(t, Options{}, script)
+	compiled := runTS(t, Options{CompiledChecks: true}, script)
+	checkOutput(t, compiled, interpreted)
+	// Both succeed and fail in places.
+	for _, s := range []string{"\nparse {", "\nparse Error: ", "\nverify [{", "\nverify Error: ", "\nresult {", "\nerror {", "\nerror Error: "} {
+		if !strings.Contains(interpreted, s) {
+			t.Fatalf("output does not contain %q:\n%s", s, interpreted)
+		}
+	}
+}
+
+func TestLazyRuntimeTypes(t *testing.T) {
+	// Parsers only have the types they need, which must include referenced types.
+	const script = `import {parser} from './api.ts'
+const user = {ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: [], Attrs: {}, Kind: 'admin', Friend: null, Data: [], Other: {type: 't', 'my-field': {type: 'u', 'my-field': null}}}
+console.log(parser.User(user).Created instanceof Date)
+try {
+	parser.User({...user, Kind: 'bogus'})
+} catch (err) {
+	console.log('' + err)
+}
+`
+	checkOutput(t, runTS(t, Options{RuntimeTypes: "lazy"}, script), `
+true
+Error: User.Kind: unknown value bogus for named strings Kind
+`)
+}
+
+func TestStandaloneFunctionCall(t *testing.T) {
+	// Standalone functions use the options of the config, with defaults for
+	// fields not set. A client can be used as config.
+	const script = `import {Client, Stats, delete0} from './api.ts'
+class XMLHttpRequest {
+	status = 200
+	responseText = JSON.stringify({result: [[1], 2]})
+	onload = () => {}
+	open(method: string, url: string) {
+		console.log(method, url)
+	}
+	setRequestHeader() {}
+	send(body: string) {
+		console.log(body)
+		this.onload()
+	}
+}
+(globalThis as any).window = {XMLHttpRequest}
+console.log(JSON.stringify(await Stats({options: {baseURL: 'http://example/api/'}}, {a: 1}, new Date(0), null)))
+console.log(JSON.stringify(await Stats(new Client(), {a: 1}, new Date(0), null)))
+try {
+	await delete0({}, ['1'], 'bogus' as any)
+} catch (err) {
+	console.log('' + err)
+}
+`
+	checkOutput(t, runTS(t, Options{StandaloneFunctions: true}, script), `
+POST http://example/api/Stats
+{"params":[{"a":1},"1970-01-01T00:00:00.000Z",null]}
+[[1],2]
+POST http://localhost/api/Stats
+{"params":[{"a":1},"1970-01-01T00:00:00.000Z",null]}
+[[1],2]
+Error: params[1]: unknown value bogus for named strings Kind
+`)
+}
+
+// xhrTS replaces XMLHttpRequest with one that prints requests and responds with
+// the JSON of the variable response, for testing calls.
+const xhrTS = `let response: any = null
+class XMLHttpRequest {
+	status = 200
+	responseText = ''
+	onload = () => {}
+	open(method: string, url: string) {
+		console.log(method, url)
+	}
+	setRequestHeader() {}
+	send(body: string) {
+		console.log(body)
+		this.responseText = JSON.stringify({result: response})
+		this.onload()
+	}
+}
+(globalThis as any).window = {XMLHttpRequest}
+`
+
+func TestNamedParamsCall(t *testing.T) {
+	const script = `import {Client} from './api.ts'
+` + xhrTS + `
+const client = new Client()
+response = [[1], 2]
+console.log(JSON.stringify(await client.Stats({x: 'x', when: new Date(0), m: {a: 1}})))
+response = null
+await client.delete({kind: 'regular' as any, ids: ['1']})
+`
+	checkOutput(t, runTS(t, Options{NamedParams: true}, script), `
+POST http://localhost/api/Stats
+{"params":[{"a":1},"1970-01-01T00:00:00.000Z","x"]}
+[[1],2]
+POST http://localhost/api/delete
+{"params":[["1"],"regular"]}
+`)
+}
+
+func TestResultsObject(t *testing.T) {
+	const script = `import {Client} from './api.ts'
+` + xhrTS + `
+response = [[1, 2], 1]
+console.log(JSON.stringify(await new Client().Stats({}, new Date(0), null)))
+`
+	checkOutput(t, runTS(t, Options{Results: "object"}, script), `
+POST http://localhost/api/Stats
+{"params":[{},"1970-01-01T00:00:00.000Z",null]}
+{"r0":[1,2],"r1":1}
+`)
+}
+
+func TestWarnDeprecatedCall(t *testing.T) {
+	// The warning is only logged for the first call.
+	const script = `import {Client} from './api.ts'
+` + xhrTS + `
+console.warn = (msg: string) => console.log('warning:', msg)
+const client = new Client()
+response = [{ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: [], Attrs: {}, Kind: 'admin', Friend: null, Data: [], Other: {type: 't', 'my-field': null}}, 'token']
+await client.Login('x', 1)
+await client.Login('x', 1)
+`
+	checkOutput(t, runTS(t, Options{WarnDeprecated: true}, script), `
+warning: sherpa: function Login is deprecated: Use Login2.
+POST http://localhost/api/Login
+{"params":["x",1]}
+POST http://localhost/api/Login
+{"params":["x",1]}
+`)
+}
+
+func TestConstructors(t *testing.T) {
+	// Constructors don't call parse functions of mapped types, they may not accept
+	// zero values.
+	const script = `import {newUser} from './api.ts'
+const u = newUser({Name: 'x'})
+console.log(u.Name, u.Kind, u.Created.toISOString(), JSON.stringify(u.Other))
+`
+	opts := Options{Constructors: true, TypeMappings: []TypeMapping{{Name: "Kind", Type: "string", Parse: "(v: string) => { if (!v) { throw new Error('empty') } return v }"}}}
+	checkOutput(t, runTS(t, opts, script), `x null 0001-01-01T00:00:00.000Z {"type":"","my-field":null}`)
+}
+
+func TestCloneEqualValues(t *testing.T) {
+	const script = `import {clone, equal, parser} from './api.ts'
+const u = parser.User({ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: ['a'], Attrs: {a: 'b', c: null}, Kind: 'admin', Friend: null, Data: [1], Other: {type: 't', 'my-field': {type: 'u', 'my-field': null}}})
+const c = clone('User', u)
+console.log(equal('User', u, c), c !== u, c.Created !== u.Created, c.Tags !== u.Tags, c.Other['my-field'] !== u.Other['my-field'])
+c.Created.setTime(0)
+console.log(equal('User', u, c), u.Created.toISOString())
+const d = clone('User', u)
+d.Attrs = {c: null, a: 'b'}
+console.log(equal('User', u, d))
+d.Other['my-field']!.type = 'v'
+console.log(equal('User', u, d), u.Other['my-field']!.type)
+`
+	checkOutput(t, runTS(t, Options{CloneEqual: true}, script), `
+true true true true true
+false 2024-01-02T03:04:05.000Z
+true
+false u
+`)
+}
+
+func TestSplitTypesCall(t *testing.T) {
+	// With split types, parameters can have timestamps as string, as in the In
+	// types. Guards check for the type itself.
+	const script = `import {Client, parser, isUser} from './api.ts'
+` + xhrTS + `
+const json = {ID: 1, Name: 'x', Created: '2024-01-02T03:04:05Z', Tags: [], Attrs: {}, Kind: 'admin', Friend: null, Data: [], Other: {type: 't', 'my-field': null}}
+const u = parser.User(structuredClone(json))
+response = json
+const r = await new Client().withOptions({splitTypes: false} as any).Echo({...u, Created: '2024-01-02T03:04:05Z'}, null)
+console.log(r.Created instanceof Date, isUser(u), isUser({...u, Created: '2024-01-02T03:04:05Z'}))
+`
+	checkOutput(t, runTS(t, Options{SplitTypes: true, Guards: true}, script), `
+POST http://localhost/api/Echo
+{"params":[{"ID":1,"Name":"x","Created":"2024-01-02T03:04:05Z","Tags":[],"Attrs":{},"Kind":"admin","Friend":null,"Data":[],"Other":{"type":"t","my-field":null}},null]}
+true true false
+`)
+}
@@ -0,0 +1,69 @@
+package sherpats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TypeMapping makes a named type from the sherpadoc, e.g. a Strings type with
+// decimal numbers, a different TypeScript type in the generated code, e.g. a
+// class from a decimal library. Values are verified as the sherpadoc type, and
+// converted with the Parse and Serialize functions.
+type TypeMapping struct {
+	Name      string // Named type in the sherpadoc.
+	Import    string // Import statement added to the generated code, optional.
+	Type      string // TypeScript type, Name if empty.
+	Parse     string // Expression for a function converting a verified JSON value to Type, optional.
+	Serialize string // Expression for a function converting Type to a JSON value, optional.
+}
+
+// ParseTypeMappings reads type mappings as a JSON array of objects with the
+// fields of TypeMapping. For example:
+//
+//	[
+//		{
+//			"Name": "Decimal",
+//			"Import": "import {Decimal} from \"decimal.js\"",
+//			"Parse": "(v: string) => new Decimal(v)",
+//			"Serialize": "(v: Decimal) => v.toString()"
+//		}
+//	]
+//
+// TypeScript types and expressions can contain any character, JSON strings keep
+// them unambiguous. Unknown fields and mappings without name are an error.
+func ParseTypeMappings(r io.Reader) ([]TypeMapping, error) {
+	var l []TypeMapping
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&l); err != nil {
+		return nil, fmt.Errorf("parsing json: %s", err)
+	}
+	for i, m := range l {
+		if m.Name == "" {
+			return nil, fmt.Errorf("type mapping %d: missing name", i)
+		}
+	}
+	return l, nil
+}
+
+// hasTypeHooks returns whether a type mapping has a parse or serialize function,
+// for which the generated code has hooks.
+func hasTypeHooks(opts Options) bool {
+	for _, m := range opts.TypeMappings {
+		if m.Parse != "" || m.Serialize != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// findTypeMapping returns the mapping for a named type, or nil.
+func findTypeMapping(l []TypeMapping, name string) *TypeMapping {
+	for i := range l {
+		if l[i].Name == name {
+			return &l[i]
+		}
+	}
+	return nil
+}
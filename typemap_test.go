@@ -0,0 +1,60 @@
+package sherpats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTypeMappings(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		mappings []TypeMapping
+		err      string
+	}{
+		{"empty", `[]`, []TypeMapping{}, ""},
+		{
+			"full",
+			`[{"Name": "Decimal", "Import": "import {Decimal} from \"decimal.js\"", "Parse": "(v: string) => new Decimal(v)", "Serialize": "(v: Decimal) => v.toString()"}]`,
+			[]TypeMapping{{Name: "Decimal", Import: `import {Decimal} from "decimal.js"`, Parse: "(v: string) => new Decimal(v)", Serialize: "(v: Decimal) => v.toString()"}},
+			"",
+		},
+		{
+			"type only",
+			`[{"Name": "Celsius", "Type": "number & {__celsius: true}"}, {"Name": "Email"}]`,
+			[]TypeMapping{{Name: "Celsius", Type: "number & {__celsius: true}"}, {Name: "Email"}},
+			"",
+		},
+		{"missing name", `[{"Name": "Decimal"}, {"Type": "string"}]`, nil, "type mapping 1: missing name"},
+		{"unknown field", `[{"Name": "Decimal", "Parser": "x"}]`, nil, "parsing json: "},
+		{"not an array", `{"Name": "Decimal"}`, nil, "parsing json: "},
+		{"bad json", `[{"Name": }]`, nil, "parsing json: "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, err := ParseTypeMappings(strings.NewReader(test.in))
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Fatalf("got error %v, expected error starting with %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !reflect.DeepEqual(l, test.mappings) {
+				t.Fatalf("got mappings %#v, expected %#v", l, test.mappings)
+			}
+		})
+	}
+}
+
+func TestHasTypeHooks(t *testing.T) {
+	if hasTypeHooks(Options{TypeMappings: []TypeMapping{{Name: "Celsius", Type: "number"}}}) {
+		t.Fatalf("type hooks for mapping with only a type")
+	}
+	if !hasTypeHooks(Options{TypeMappings: []TypeMapping{{Name: "Celsius", Type: "number"}, {Name: "Decimal", Parse: "(v: string) => new Decimal(v)"}}}) {
+		t.Fatalf("no type hooks for mapping with parse function")
+	}
+}